@@ -0,0 +1,121 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// Output formats, for --format. Commands that support the structured
+// formats write their output as a table: in JSON, one object per row
+// (JSON lines) with the column names as keys; in CSV, a header row of
+// the column names and then the rows. A command's columns don't depend
+// on its flags or the core, so scripts can rely on them.
+const (
+	formatText = "text"
+	formatJSON = "json"
+	formatCSV  = "csv"
+)
+
+// structured reports whether output is to be a table, for --format json
+// or csv, rather than text.
+func structured() bool {
+	return cfg.format != formatText
+}
+
+// checkFormat exits if --format names an unknown format.
+func checkFormat() {
+	switch cfg.format {
+	case formatText, formatJSON, formatCSV:
+	default:
+		exitf("unknown --format %q; want text, json or csv\n", cfg.format)
+	}
+}
+
+// A tableWriter writes the rows of a command's output in the format
+// given by --format, which must be json or csv.
+type tableWriter struct {
+	cols []string
+	json *bufio.Writer // for --format json
+	csv  *csv.Writer   // for --format csv
+}
+
+// newTable returns a tableWriter writing rows with the given columns to
+// w. For CSV, it writes the header row.
+func newTable(w io.Writer, cols ...string) *tableWriter {
+	t := &tableWriter{cols: cols}
+	switch cfg.format {
+	case formatJSON:
+		t.json = bufio.NewWriter(w)
+	case formatCSV:
+		t.csv = csv.NewWriter(w)
+		t.csv.Write(cols)
+	default:
+		panic("newTable with --format " + cfg.format)
+	}
+	return t
+}
+
+// row writes a row of values, one per column. Values are written as
+// JSON values or formatted with fmt. Addresses are written as
+// hexadecimal strings ("0xc000010000"), nil as JSON null or an empty
+// CSV field.
+func (t *tableWriter) row(vals ...any) {
+	if len(vals) != len(t.cols) {
+		panic(fmt.Sprintf("table row has %d values for %d columns", len(vals), len(t.cols)))
+	}
+	for i, v := range vals {
+		if a, ok := v.(core.Address); ok {
+			vals[i] = fmt.Sprintf("%#x", a)
+		}
+	}
+	if t.json != nil {
+		// Keep the columns in order, which a map wouldn't.
+		b := []byte{'{'}
+		for i, v := range vals {
+			if i > 0 {
+				b = append(b, ',')
+			}
+			k, _ := json.Marshal(t.cols[i])
+			x, err := json.Marshal(v)
+			if err != nil {
+				exitf("%v\n", err)
+			}
+			b = append(append(append(b, k...), ':'), x...)
+		}
+		b = append(b, '}', '\n')
+		t.json.Write(b)
+		return
+	}
+	rec := make([]string, len(vals))
+	for i, v := range vals {
+		if v != nil {
+			rec[i] = fmt.Sprint(v)
+		}
+	}
+	t.csv.Write(rec)
+}
+
+// flush writes any buffered rows, and exits if writing failed.
+func (t *tableWriter) flush() {
+	var err error
+	if t.json != nil {
+		err = t.json.Flush()
+	} else {
+		t.csv.Flush()
+		err = t.csv.Error()
+	}
+	if err != nil {
+		exitf("%v\n", err)
+	}
+}
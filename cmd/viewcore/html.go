@@ -48,7 +48,7 @@ func serveHTML(c *gocore.Process, port int, async bool) {
 		fmt.Fprintf(w, "<h3>%s</h3>\n", html.EscapeString(typeName(c, x)))
 		fmt.Fprintf(w, "<h3>%d bytes</h3>\n", size)
 
-		if typ != nil && repeat == 1 && typ.String() == "runtime.g" {
+		if typ != nil && repeat == 1 && typ.Name == "runtime.g" {
 			found := false
 			for _, g := range c.Goroutines() {
 				if g.Addr() == addr {
@@ -75,17 +75,17 @@ func serveHTML(c *gocore.Process, port int, async bool) {
 			}
 			end = n * typ.Size
 		}
-		for i := end; i < size; i += c.Process().PtrSize() {
+		for i := end; i < size; i += c.PtrSize() {
 			fmt.Fprintf(w, "<tr><td>f%d</td><td colspan=\"2\">?</td>", i)
 			if c.IsPtr(addr.Add(i)) {
 				fmt.Fprintf(w, "<td>%s</td>", htmlPointer(c, c.Process().ReadPtr(addr.Add(i))))
 			} else {
 				fmt.Fprintf(w, "<td><pre>")
-				for j := int64(0); j < c.Process().PtrSize(); j++ {
+				for j := int64(0); j < c.PtrSize(); j++ {
 					fmt.Fprintf(w, "%02x ", c.Process().ReadUint8(addr.Add(i+j)))
 				}
 				fmt.Fprintf(w, "</pre></td><td><pre>")
-				for j := int64(0); j < c.Process().PtrSize(); j++ {
+				for j := int64(0); j < c.PtrSize(); j++ {
 					r := c.Process().ReadUint8(addr.Add(i + j))
 					if r >= 32 && r <= 126 {
 						fmt.Fprintf(w, "%s", html.EscapeString(string(rune(r))))
@@ -106,19 +106,9 @@ func serveHTML(c *gocore.Process, port int, async bool) {
 				return false
 			}
 			if r != nil {
-				fmt.Fprintf(w, "%s%s", r.Name, typeFieldName(r.Type, i))
+				fmt.Fprintf(w, "%s%s", r.Name, r.Type.FieldNameAt(i))
 			} else {
-				t, r := c.Type(z)
-				if t == nil {
-					fmt.Fprintf(w, "%s", htmlPointer(c, c.Addr(z).Add(i)))
-				} else {
-					idx := ""
-					if r > 1 {
-						idx = fmt.Sprintf("[%d]", i/t.Size)
-						i %= t.Size
-					}
-					fmt.Fprintf(w, "%s%s%s", htmlPointer(c, c.Addr(z)), idx, typeFieldName(t, i))
-				}
+				fmt.Fprintf(w, "%s%s", htmlPointer(c, c.Addr(z)), c.FieldPath(z, i))
 			}
 			fmt.Fprintf(w, " → %s<br/>\n", htmlPointer(c, a.Add(j)))
 			nrev++
@@ -191,7 +181,7 @@ func serveHTML(c *gocore.Process, port int, async bool) {
 	})
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		fmt.Fprintf(w, "<h1>core dump viewer</h1>\n")
-		fmt.Fprintf(w, "%s<br/>\n", c.Process().Arch())
+		fmt.Fprintf(w, "%s<br/>\n", c.Arch())
 		fmt.Fprintf(w, "%s<br/>\n", c.BuildVersion())
 		fmt.Fprintf(w, "<a href=\"goroutines\">goroutines</a><br/>\n")
 		fmt.Fprintf(w, "<a href=\"globals\">globals</a><br/>\n")
@@ -201,7 +191,7 @@ func serveHTML(c *gocore.Process, port int, async bool) {
 		all := c.Stats().Value
 		var p func(*gocore.Statistic, string)
 		p = func(s *gocore.Statistic, prefix string) {
-			fmt.Fprintf(w, "<tr><td>%s%s</td><td align=right>%d</td><td align=right>%.2f</td></tr>\n", prefix, s.Name, s.Value, float64(s.Value)/float64(all)*100)
+			fmt.Fprintf(w, "<tr><td>%s%s</td><td align=right>%d</td><td align=right>%.2f</td></tr>\n", prefix, s.Name, s.Value, percent(s.Value, all))
 			for c := range s.Children() {
 				p(c, prefix+"..")
 			}
@@ -227,7 +217,7 @@ func htmlObject(w http.ResponseWriter, c *gocore.Process, name string, a core.Ad
 	switch t.Kind {
 	case gocore.KindBool:
 		v := c.Process().ReadUint8(a) != 0
-		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%t</td></tr>\n", name, html.EscapeString(t.String()), v)
+		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%t</td></tr>\n", name, html.EscapeString(c.TypeName(t)), v)
 	case gocore.KindInt:
 		var v int64
 		switch t.Size {
@@ -240,7 +230,7 @@ func htmlObject(w http.ResponseWriter, c *gocore.Process, name string, a core.Ad
 		case 8:
 			v = c.Process().ReadInt64(a)
 		}
-		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%d</td></tr>\n", name, html.EscapeString(t.String()), v)
+		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%d</td></tr>\n", name, html.EscapeString(c.TypeName(t)), v)
 	case gocore.KindUint:
 		var v uint64
 		switch t.Size {
@@ -253,7 +243,7 @@ func htmlObject(w http.ResponseWriter, c *gocore.Process, name string, a core.Ad
 		case 8:
 			v = c.Process().ReadUint64(a)
 		}
-		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%d</td></tr>\n", name, html.EscapeString(t.String()), v)
+		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%d</td></tr>\n", name, html.EscapeString(c.TypeName(t)), v)
 	case gocore.KindFloat:
 		var v float64
 		switch t.Size {
@@ -262,7 +252,7 @@ func htmlObject(w http.ResponseWriter, c *gocore.Process, name string, a core.Ad
 		case 8:
 			v = math.Float64frombits(c.Process().ReadUint64(a))
 		}
-		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%f</td></tr>\n", name, html.EscapeString(t.String()), v)
+		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%f</td></tr>\n", name, html.EscapeString(c.TypeName(t)), v)
 	case gocore.KindComplex:
 		var v complex128
 		switch t.Size {
@@ -276,31 +266,31 @@ func htmlObject(w http.ResponseWriter, c *gocore.Process, name string, a core.Ad
 				math.Float64frombits(c.Process().ReadUint64(a)),
 				math.Float64frombits(c.Process().ReadUint64(a.Add(8))))
 		}
-		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%f</td></tr>\n", name, html.EscapeString(t.String()), v)
+		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%f</td></tr>\n", name, html.EscapeString(c.TypeName(t)), v)
 	case gocore.KindEface:
 		fmt.Fprintf(w, "<tr><td rowspan=\"2\">%s</td><td rowspan=\"2\">interface{}</td><td>*runtime._type</td><td>%s</td>", name, htmlPointerAt(c, a, live))
 		if live == nil || live[a] {
 			dt := c.DynamicType(t, a)
 			if dt != nil {
-				fmt.Fprintf(w, "<td>%s</td>", dt.Name)
+				fmt.Fprintf(w, "<td>%s</td>", html.EscapeString(c.TypeName(dt)))
 			}
 		}
 		fmt.Fprintf(w, "</tr>\n")
-		fmt.Fprintf(w, "<tr><td>unsafe.Pointer</td><td>%s</td></tr>\n", htmlPointerAt(c, a.Add(c.Process().PtrSize()), live))
+		fmt.Fprintf(w, "<tr><td>unsafe.Pointer</td><td>%s</td></tr>\n", htmlPointerAt(c, a.Add(c.PtrSize()), live))
 	case gocore.KindIface:
 		fmt.Fprintf(w, "<tr><td rowspan=\"2\">%s</td><td rowspan=\"2\">interface{...}</td><td>*runtime.itab</td><td>%s</td>", name, htmlPointerAt(c, a, live))
 		if live == nil || live[a] {
 			dt := c.DynamicType(t, a)
 			if dt != nil {
-				fmt.Fprintf(w, "<td>%s</td>", dt.Name)
+				fmt.Fprintf(w, "<td>%s</td>", html.EscapeString(c.TypeName(dt)))
 			}
 		}
 		fmt.Fprintf(w, "</tr>\n")
-		fmt.Fprintf(w, "<tr><td>unsafe.Pointer</td><td>%s</td></tr>\n", htmlPointerAt(c, a.Add(c.Process().PtrSize()), live))
+		fmt.Fprintf(w, "<tr><td>unsafe.Pointer</td><td>%s</td></tr>\n", htmlPointerAt(c, a.Add(c.PtrSize()), live))
 	case gocore.KindPtr:
-		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%s</td></tr>\n", name, html.EscapeString(t.String()), htmlPointerAt(c, a, live))
+		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%s</td></tr>\n", name, html.EscapeString(c.TypeName(t)), htmlPointerAt(c, a, live))
 	case gocore.KindFunc:
-		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%s</td>", name, html.EscapeString(t.String()), htmlPointerAt(c, a, live))
+		fmt.Fprintf(w, "<tr><td>%s</td><td colspan=\"2\">%s</td><td>%s</td>", name, html.EscapeString(c.TypeName(t)), htmlPointerAt(c, a, live))
 		if fn := c.Process().ReadPtr(a); fn != 0 {
 			pc := c.Process().ReadPtr(fn)
 			if f := c.FindFunc(pc); f != nil && f.Entry() == pc {
@@ -309,7 +299,7 @@ func htmlObject(w http.ResponseWriter, c *gocore.Process, name string, a core.Ad
 		}
 		fmt.Fprintf(w, "</tr>\n")
 	case gocore.KindString:
-		n := c.Process().ReadInt(a.Add(c.Process().PtrSize()))
+		n := c.Process().ReadInt(a.Add(c.PtrSize()))
 		fmt.Fprintf(w, "<tr><td rowspan=\"2\">%s</td><td rowspan=\"2\">string</td><td>*uint8</td><td>%s</td>", name, htmlPointerAt(c, a, live))
 		if live == nil || live[a] {
 			if n > 0 {
@@ -330,8 +320,8 @@ func htmlObject(w http.ResponseWriter, c *gocore.Process, name string, a core.Ad
 		fmt.Fprintf(w, "<tr><td>int</td><td>%d</td></tr>\n", n)
 	case gocore.KindSlice:
 		fmt.Fprintf(w, "<tr><td rowspan=\"3\">%s</td><td rowspan=\"3\">%s</td><td>*%s</td><td>%s</td></tr>\n", name, t, t.Elem, htmlPointerAt(c, a, live))
-		fmt.Fprintf(w, "<tr><td>int</td><td>%d</td></tr>\n", c.Process().ReadInt(a.Add(c.Process().PtrSize())))
-		fmt.Fprintf(w, "<tr><td>int</td><td>%d</td></tr>\n", c.Process().ReadInt(a.Add(c.Process().PtrSize()*2)))
+		fmt.Fprintf(w, "<tr><td>int</td><td>%d</td></tr>\n", c.Process().ReadInt(a.Add(c.PtrSize())))
+		fmt.Fprintf(w, "<tr><td>int</td><td>%d</td></tr>\n", c.Process().ReadInt(a.Add(c.PtrSize()*2)))
 	case gocore.KindArray:
 		s := t.Elem.Size
 		n := t.Count
@@ -363,16 +353,7 @@ func htmlPointer(c *gocore.Process, a core.Address) string {
 	if i == 0 {
 		return s
 	}
-	t, r := c.Type(x)
-	if t == nil || i >= r*t.Size {
-		return fmt.Sprintf("%s+%d", s, i)
-	}
-	idx := ""
-	if r > 1 {
-		idx = fmt.Sprintf("[%d]", i/t.Size)
-		i %= t.Size
-	}
-	return fmt.Sprintf("%s%s%s", s, idx, typeFieldName(t, i))
+	return s + c.FieldPath(x, i)
 }
 
 func htmlPointerAt(c *gocore.Process, a core.Address, live map[core.Address]bool) string {
@@ -396,33 +377,3 @@ func tableStyle(w http.ResponseWriter) {
 	fmt.Fprintf(w, "</style>\n")
 
 }
-
-// Returns the name of the field at offset off in x.
-func objField(c *gocore.Process, x gocore.Object, off int64) string {
-	t, r := c.Type(x)
-	if t == nil {
-		return fmt.Sprintf("f%d", off)
-	}
-	s := ""
-	if r > 1 {
-		s = fmt.Sprintf("[%d]", off/t.Size)
-		off %= t.Size
-	}
-	return s + typeFieldName(t, off)
-}
-
-func objRegion(c *gocore.Process, x gocore.Object, off int64) string {
-	t, r := c.Type(x)
-	if t == nil {
-		return fmt.Sprintf("f%d", off)
-	}
-	if off == 0 {
-		return ""
-	}
-	s := ""
-	if r > 1 {
-		s = fmt.Sprintf("[%d]", off/t.Size)
-		off %= t.Size
-	}
-	return s + typeFieldName(t, off)
-}
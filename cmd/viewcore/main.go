@@ -13,16 +13,25 @@
 package main
 
 import (
+	"debug/buildinfo"
+	"debug/dwarf"
+	"debug/elf"
+	"errors"
 	"fmt"
 	"io"
+	"io/fs"
+	"math"
 	"os"
+	"regexp"
 	"runtime/debug"
 	"runtime/pprof"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
 	"sync"
 	"text/tabwriter"
+	"time"
 
 	"github.com/chzyer/readline"
 	"github.com/spf13/cobra"
@@ -86,6 +95,20 @@ var (
 		Run:   runGoroutines,
 	}
 
+	cmdGoroutine = &cobra.Command{
+		Use:   "goroutine <id>",
+		Short: "print the state, frames, and local variables of one goroutine",
+		Args:  cobra.ExactArgs(1),
+		Run:   runGoroutine,
+	}
+
+	cmdGlobals = &cobra.Command{
+		Use:   "globals",
+		Short: "print the values of global variables",
+		Args:  cobra.ExactArgs(0),
+		Run:   runGlobals,
+	}
+
 	cmdHistogram = &cobra.Command{
 		Use:     "histogram",
 		Aliases: []string{"histo"},
@@ -97,6 +120,13 @@ var (
 		Run:  runHistogram,
 	}
 
+	cmdTypes = &cobra.Command{
+		Use:   "types",
+		Short: "list all known Go types with their sizes and live instance counts",
+		Args:  cobra.ExactArgs(0),
+		Run:   runTypes,
+	}
+
 	cmdBreakdown = &cobra.Command{
 		Use:   "breakdown",
 		Short: "print memory use by class",
@@ -104,6 +134,16 @@ var (
 		Run:   runBreakdown,
 	}
 
+	cmdSpans = &cobra.Command{
+		Use:   "spans",
+		Short: "print heap span utilization by size class, and the least utilized spans",
+		Long: "print heap span utilization by size class, and the least utilized spans.\n" +
+			"A span with a few live objects keeps the whole span in use, so many\n" +
+			"sparsely used spans mean the heap is fragmented.",
+		Args: cobra.ExactArgs(0),
+		Run:  runSpans,
+	}
+
 	cmdObjects = &cobra.Command{
 		Use:   "objects",
 		Short: "print a list of all live objects",
@@ -111,6 +151,13 @@ var (
 		Run:   runObjects,
 	}
 
+	cmdStringdump = &cobra.Command{
+		Use:   "stringdump",
+		Short: "print the contents of all strings and []byte slices in live objects",
+		Args:  cobra.ExactArgs(0),
+		Run:   runStringdump,
+	}
+
 	cmdObjgraph = &cobra.Command{
 		Use:   "objgraph <output_filename>",
 		Short: "dump object graph (dot)",
@@ -118,6 +165,27 @@ var (
 		Run:   runObjgraph,
 	}
 
+	cmdExport = &cobra.Command{
+		Use:   "export <output_filename>",
+		Short: "dump object graph (JSON lines)",
+		Args:  cobra.ExactArgs(1),
+		Run:   runExport,
+	}
+
+	cmdHeapdump = &cobra.Command{
+		Use:   "heapdump <output_filename>",
+		Short: "dump heap in runtime/debug.WriteHeapDump format",
+		Args:  cobra.ExactArgs(1),
+		Run:   runHeapdump,
+	}
+
+	cmdTrace = &cobra.Command{
+		Use:   "trace <output_filename>",
+		Short: "dump the buffered execution trace, if runtime/trace was on",
+		Args:  cobra.ExactArgs(1),
+		Run:   runTrace,
+	}
+
 	cmdReachable = &cobra.Command{
 		Use:   "reachable <address>",
 		Short: "find path from root to an object",
@@ -125,6 +193,20 @@ var (
 		Run:   runReachable,
 	}
 
+	cmdRetains = &cobra.Command{
+		Use:   "retains <address>",
+		Short: "list all objects reachable from an object",
+		Args:  cobra.ExactArgs(1),
+		Run:   runRetains,
+	}
+
+	cmdWhatis = &cobra.Command{
+		Use:   "whatis <address>",
+		Short: "describe what lives at an address",
+		Args:  cobra.ExactArgs(1),
+		Run:   runWhatis,
+	}
+
 	cmdHTML = &cobra.Command{
 		Use:   "html",
 		Short: "start an http server for browsing core file data on the port specified with -port",
@@ -133,10 +215,12 @@ var (
 	}
 
 	cmdRead = &cobra.Command{
-		Use:   "read <address> [<size>]",
+		Use:   "read <address|symbol|global|func> [<size>]",
 		Short: "read a chunk of memory", // oh very helpful!
-		Args:  cobra.RangeArgs(1, 2),
-		Run:   runRead,
+		Long: "read a chunk of memory, given its hex address, or the name of a symbol,\n" +
+			"global variable or function. The size defaults to that of the global, or 256 bytes.",
+		Args: cobra.RangeArgs(1, 2),
+		Run:  runRead,
 	}
 )
 
@@ -147,32 +231,74 @@ type config struct {
 	corefile string
 
 	// flags
-	base    string
-	exePath string
-	cpuprof string // TODO: move to subcommand config.
+	base         string
+	exePath      string
+	cpuprof      string // TODO: move to subcommand config.
+	strict       bool
+	conservative bool
+	noDWARF      bool
+	shortTypes   bool
+	format       string
+	rtDWARF      string
+	page         bool
 }
 
 var cfg config
 
 func init() {
-	cmdRoot.PersistentFlags().StringVar(&cfg.base, "base", "", "root directory to find core dump file references")
+	cmdRoot.PersistentFlags().StringVar(&cfg.base, "base", "", "list of root directories to find core dump file references, separated like PATH")
 	cmdRoot.PersistentFlags().StringVar(&cfg.exePath, "exe", "", "main executable file")
 	cmdRoot.PersistentFlags().StringVar(&cfg.cpuprof, "prof", "", "write cpu profile of viewcore to this file for viewcore's developers")
+	cmdRoot.PersistentFlags().BoolVar(&cfg.strict, "strict", false, "warn about conflicting DWARF type information")
+	cmdRoot.PersistentFlags().BoolVar(&cfg.conservative, "conservative", false, "scan frames with missing stack maps conservatively, instead of assuming they hold no pointers")
+	cmdRoot.PersistentFlags().BoolVar(&cfg.noDWARF, "no-dwarf", false, "don't use the executable's DWARF, for stripped binaries; objects are typed only from runtime type information")
+	cmdRoot.PersistentFlags().BoolVar(&cfg.shortTypes, "short-types", false, "show type names with package names instead of full import paths, like bar.Baz for github.com/foo/bar.Baz")
+	cmdRoot.PersistentFlags().StringVar(&cfg.format, "format", formatText, "output format of overview, mappings, goroutines, histogram, objects and breakdown: text, json (JSON lines) or csv")
+	cmdRoot.PersistentFlags().BoolVar(&cfg.page, "page", false, "on a terminal, show each command's output through $PAGER (default less)")
+	cmdRoot.PersistentFlags().StringVar(&cfg.rtDWARF, "runtime-dwarf", "", "with --no-dwarf, a binary built by the same Go version as the core's, to read the runtime's DWARF from (default viewcore itself)")
+
+	cmdRoot.PersistentPreRun = func(cmd *cobra.Command, args []string) {
+		checkFormat()
+		startOutput(cmd, args)
+	}
+	cmdRoot.PersistentPostRun = func(*cobra.Command, []string) { stopOutput() }
 
 	// subcommand flags
 	cmdHTML.Flags().IntP("port", "p", 8080, "port for http server")
 
+	cmdGoroutines.Flags().Bool("locals", false, "also list the local variables of each frame")
+	cmdGoroutines.Flags().String("sort", "", "sort goroutines by: stack (stack in use, largest first)")
 	cmdHistogram.Flags().Int("top", 0, "reports only top N entries if N>0")
+	cmdHistogram.Flags().Bool("retained", false, "group by type and sort by retained size")
+	cmdHistogram.Flags().String("sort", "bytes", "without --retained, sort by: bytes, count, size or name")
+	cmdHistogram.Flags().Bool("reverse", false, "reverse the sort order")
+	cmdSpans.Flags().Int("top", 10, "list the N spans with the smallest fraction of live bytes (0 for none)")
+	cmdStringdump.Flags().Int("min-len", 0, "only print strings at least this long")
+	cmdStringdump.Flags().String("grep", "", "only print strings matching this regular expression")
+	cmdMappings.Flags().Bool("gaps", false, "also list unmapped ranges between mappings")
+	cmdGlobals.Flags().String("grep", "", "only print globals whose names match this regular expression")
+	cmdReachable.Flags().Bool("all", false, "print a path from every root that can reach the object, not just the shortest")
+	cmdReachable.Flags().Int("max", 10, "with --all, print at most this many paths (0 for no limit)")
 
 	cmdRoot.AddCommand(
 		cmdOverview,
 		cmdMappings,
 		cmdGoroutines,
+		cmdGoroutine,
+		cmdGlobals,
 		cmdHistogram,
+		cmdTypes,
 		cmdBreakdown,
+		cmdSpans,
 		cmdObjects,
+		cmdStringdump,
 		cmdObjgraph,
+		cmdExport,
+		cmdHeapdump,
+		cmdTrace,
 		cmdReachable,
+		cmdRetains,
+		cmdWhatis,
 		cmdHTML,
 		cmdRead)
 
@@ -270,20 +396,52 @@ func readCore() (*core.Process, *gocore.Process, error) {
 	if cc.cfg == cfg {
 		return cc.coreP, cc.gocoreP, cc.err
 	}
+	if cc.coreP != nil {
+		cc.coreP.Close()
+		cc.coreP, cc.gocoreP = nil, nil
+	}
 	c, err := core.Core(cfg.corefile, cfg.base, cfg.exePath)
+	if errors.Is(err, fs.ErrNotExist) && cfg.exePath == "" {
+		return nil, nil, fmt.Errorf("%v; consider specifying the --exe flag", err)
+	}
 	if err != nil {
 		return nil, nil, err
 	}
-	p, err := gocore.Core(c)
-	if os.IsNotExist(err) && cfg.exePath == "" {
-		return nil, nil, fmt.Errorf("%v; consider specifying the --exe flag", err)
+	var opts gocore.Options
+	if cfg.strict {
+		opts.Flags |= gocore.FlagStrictTypes
+	}
+	if cfg.conservative {
+		opts.Flags |= gocore.FlagConservativeFrames
+	}
+	if cfg.noDWARF {
+		opts.RuntimeDWARF, err = readRuntimeDWARF(c, cfg.rtDWARF)
+		if err != nil {
+			c.Close()
+			return nil, nil, err
+		}
+	}
+	opts.Progress = progressBar()
+	p, err := gocore.CoreWithOptions(c, opts)
+	if opts.Progress != nil {
+		clearProgress()
 	}
 	if err != nil {
+		c.Close()
+		if _, dwarfErr := c.DWARF(); dwarfErr != nil && !cfg.noDWARF {
+			return nil, nil, fmt.Errorf("%v; consider specifying the --no-dwarf flag", err)
+		}
 		return nil, nil, err
 	}
+	if cfg.shortTypes {
+		p.SetTypeNameFormatter(shortTypeName)
+	}
 	for _, w := range c.Warnings() {
 		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
 	}
+	for _, w := range p.Warnings() {
+		fmt.Fprintf(os.Stderr, "WARNING: %s\n", w)
+	}
 	cc.cfg = cfg
 	cc.coreP = c
 	cc.gocoreP = p
@@ -291,6 +449,52 @@ func readCore() (*core.Process, *gocore.Process, error) {
 	return c, p, nil
 }
 
+// readRuntimeDWARF returns the DWARF of the binary at path, or of
+// viewcore itself if path is empty, for use as gocore's RuntimeDWARF
+// with c. The binary must be built by the same Go version, for the
+// same architecture, as c's.
+func readRuntimeDWARF(c *core.Process, path string) (*dwarf.Data, error) {
+	if path == "" {
+		exe, err := os.Executable()
+		if err != nil {
+			return nil, err
+		}
+		path = exe
+	}
+	info, err := buildinfo.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	syms, err := c.Symbols()
+	if err != nil {
+		return nil, fmt.Errorf("--no-dwarf needs the executable's symbol table: %v", err)
+	}
+	a, ok := syms["runtime.buildVersion"]
+	if !ok {
+		return nil, errors.New("runtime.buildVersion not found")
+	}
+	b := make([]byte, c.ReadInt(a.Add(c.PtrSize())))
+	c.ReadAt(b, c.ReadPtr(a))
+	if version := string(b); version != info.GoVersion {
+		return nil, fmt.Errorf("core is from a binary built by %s, but %s is built by %s; use --runtime-dwarf to name one built by the same version", version, path, info.GoVersion)
+	}
+	for _, s := range info.Settings {
+		if s.Key == "GOARCH" && s.Value != c.Arch() {
+			return nil, fmt.Errorf("core is from %s, but %s is built for %s", c.Arch(), path, s.Value)
+		}
+	}
+	f, err := elf.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	d, err := f.DWARF()
+	if err != nil {
+		return nil, fmt.Errorf("reading runtime DWARF from %s: %v", path, err)
+	}
+	return d, nil
+}
+
 func runRoot(cmd *cobra.Command, args []string) {
 	if cfg.corefile == "" {
 		cmd.Usage()
@@ -305,7 +509,10 @@ func runRoot(cmd *cobra.Command, args []string) {
 	}
 
 	// Create a dummy root to run in shell.
-	root := &cobra.Command{}
+	root := &cobra.Command{
+		PersistentPreRun:  startOutput,
+		PersistentPostRun: cmd.PersistentPostRun,
+	}
 	// Make all subcommands of viewcore available in the shell.
 	for _, subcmd := range cmd.Commands() {
 		if subcmd.Name() == "help" {
@@ -360,6 +567,7 @@ func runRoot(cmd *cobra.Command, args []string) {
 			root.SetArgs(strings.Fields(l))
 			root.Execute()
 		})
+		stopOutput() // in case the command panicked
 		if err != nil {
 			fmt.Printf("Error while trying to run command %q: %v", l, err)
 		}
@@ -391,59 +599,201 @@ func runOverview(cmd *cobra.Command, args []string) {
 		exitf("%v\n", err)
 	}
 
-	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
-	fmt.Fprintf(t, "arch\t%s\n", p.Arch())
-	fmt.Fprintf(t, "runtime\t%s\n", c.BuildVersion())
 	var total int64
 	for _, m := range p.Mappings() {
 		total += m.Max().Sub(m.Min())
 	}
+	g, pan := c.CrashingGoroutine()
+	gc := c.GCStats()
+	pending := map[string]int{}
+	for _, f := range c.PendingFinalizers() {
+		pending[f.Kind]++
+	}
+	if structured() {
+		t := newTable(os.Stdout, "field", "value")
+		t.row("arch", p.Arch())
+		t.row("runtime", c.BuildVersion())
+		t.row("memory_bytes", total)
+		if g != nil {
+			t.row("crashed_goroutine", g.ID())
+			t.row("crash", crashMessage(c, pan))
+		} else {
+			t.row("crashed_goroutine", nil)
+			t.row("crash", nil)
+		}
+		t.row("heap_live_bytes", gc.HeapLive)
+		t.row("heap_goal_bytes", gc.HeapGoal)
+		t.row("objects", c.NumObjects())
+		t.row("object_bytes", c.LiveBytes())
+		t.row("gogc", gc.GCPercent) // negative if off
+		if gc.MemoryLimit != 0 && gc.MemoryLimit != math.MaxInt64 {
+			t.row("gomemlimit_bytes", gc.MemoryLimit)
+		} else {
+			t.row("gomemlimit_bytes", nil)
+		}
+		t.row("gc_cycles", gc.NumGC)
+		t.row("gc_running", gc.Running)
+		if gc.NumGC > 0 {
+			t.row("last_gc", gc.LastGC.UTC().Format(time.RFC3339))
+		} else {
+			t.row("last_gc", nil)
+		}
+		t.row("pending_finalizers", pending["Finalizer"])
+		t.row("pending_cleanups", pending["Cleanup"])
+		t.flush()
+		return
+	}
+
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', 0)
+	fmt.Fprintf(t, "arch\t%s\n", p.Arch())
+	fmt.Fprintf(t, "runtime\t%s\n", c.BuildVersion())
 	fmt.Fprintf(t, "memory\t%.1f MB\n", float64(total)/(1<<20))
+	if g != nil {
+		fmt.Fprintf(t, "crashed\tin goroutine %d with %s\n", g.ID(), crashMessage(c, pan))
+	}
+	fmt.Fprintf(t, "heap\t%.1f MB live, goal %.1f MB\n", float64(gc.HeapLive)/(1<<20), float64(gc.HeapGoal)/(1<<20))
+	fmt.Fprintf(t, "objects\t%d reachable, %.1f MB\n", c.NumObjects(), float64(c.LiveBytes())/(1<<20))
+	gogc := "off"
+	if gc.GCPercent >= 0 {
+		gogc = fmt.Sprint(gc.GCPercent)
+	}
+	fmt.Fprintf(t, "GOGC\t%s\n", gogc)
+	if gc.MemoryLimit != 0 && gc.MemoryLimit != math.MaxInt64 {
+		fmt.Fprintf(t, "GOMEMLIMIT\t%.1f MB\n", float64(gc.MemoryLimit)/(1<<20))
+	}
+	switch {
+	case gc.Running:
+		fmt.Fprintf(t, "gc\tcycle %d in progress\n", gc.NumGC+1)
+	case gc.NumGC > 0:
+		fmt.Fprintf(t, "gc\t%d cycles, last at %s\n", gc.NumGC, gc.LastGC.UTC().Format(time.RFC3339))
+	default:
+		fmt.Fprintf(t, "gc\tnone yet\n")
+	}
+	fmt.Fprintf(t, "pending\t%d finalizers, %d cleanups\n", pending["Finalizer"], pending["Cleanup"])
 	t.Flush()
 }
 
+// crashMessage describes the panic pan that crashed the program, or
+// says it was a fatal error if pan is nil.
+func crashMessage(c *gocore.Process, pan *gocore.Panic) string {
+	switch {
+	case pan == nil:
+		return "fatal error"
+	case pan.Message != "":
+		return "panic: " + pan.Message
+	case pan.Type != nil:
+		return fmt.Sprintf("panic: (%s) at %x", c.TypeName(pan.Type), pan.Addr)
+	}
+	return "panic: nil"
+}
+
 func runMappings(cmd *cobra.Command, args []string) {
 	p, _, err := readCore()
 	if err != nil {
 		exitf("%v\n", err)
 	}
+	showGaps, err := cmd.Flags().GetBool("gaps")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	var gaps []core.AddressRange
+	if showGaps {
+		gaps = p.Gaps()
+	}
+	if structured() {
+		t := newTable(os.Stdout, "min", "max", "perm", "source", "offset", "orig_source", "orig_offset", "stale", "gap")
+		for _, m := range p.Mappings() {
+			for len(gaps) > 0 && gaps[0].Max <= m.Min() {
+				t.row(gaps[0].Min, gaps[0].Max, nil, nil, nil, nil, nil, false, true)
+				gaps = gaps[1:]
+			}
+			file, off := m.Source()
+			var origFile, origOff any
+			if m.CopyOnWrite() {
+				origFile, origOff = m.OrigSource()
+			}
+			_, stale := m.DataSource()
+			t.row(m.Min(), m.Max(), permString(m.Perm()), file, off, origFile, origOff, stale, false)
+		}
+		t.flush()
+		return
+	}
 	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
-	fmt.Fprintf(t, "min\tmax\tperm\tsource\toriginal\t\n")
+	fmt.Fprintf(t, "min\tmax\tperm\tsource\toriginal\tstale\t\n")
 	for _, m := range p.Mappings() {
-		perm := ""
-		if m.Perm()&core.Read != 0 {
-			perm += "r"
-		} else {
-			perm += "-"
-		}
-		if m.Perm()&core.Write != 0 {
-			perm += "w"
-		} else {
-			perm += "-"
-		}
-		if m.Perm()&core.Exec != 0 {
-			perm += "x"
-		} else {
-			perm += "-"
+		for len(gaps) > 0 && gaps[0].Max <= m.Min() {
+			fmt.Fprintf(t, "%x\t%x\t\tgap (%d bytes)\t\t\t\n", gaps[0].Min, gaps[0].Max, gaps[0].Size())
+			gaps = gaps[1:]
 		}
 		file, off := m.Source()
-		fmt.Fprintf(t, "%x\t%x\t%s\t%s@%x\t", m.Min(), m.Max(), perm, file, off)
+		fmt.Fprintf(t, "%x\t%x\t%s\t%s@%x\t", m.Min(), m.Max(), permString(m.Perm()), file, off)
 		if m.CopyOnWrite() {
 			file, off = m.OrigSource()
 			fmt.Fprintf(t, "%s@%x", file, off)
 		}
+		fmt.Fprintf(t, "\t")
+		if _, stale := m.DataSource(); stale {
+			fmt.Fprintf(t, "stale")
+		}
 		fmt.Fprintf(t, "\t\n")
 	}
 	t.Flush()
 }
 
+// permString formats perm like ls: "r-x", for example.
+func permString(perm core.Perm) string {
+	s := ""
+	if perm&core.Read != 0 {
+		s += "r"
+	} else {
+		s += "-"
+	}
+	if perm&core.Write != 0 {
+		s += "w"
+	} else {
+		s += "-"
+	}
+	if perm&core.Exec != 0 {
+		s += "x"
+	} else {
+		s += "-"
+	}
+	return s
+}
+
 func runGoroutines(cmd *cobra.Command, args []string) {
+	locals, err := cmd.Flags().GetBool("locals")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	sortBy, err := cmd.Flags().GetString("sort")
+	if err != nil {
+		exitf("%v\n", err)
+	}
 	_, c, err := readCore()
 	if err != nil {
 		exitf("%v\n", err)
 	}
-	for _, g := range c.Goroutines() {
-		fmt.Printf("G stacksize=%x\n", g.Stack())
+	gs := c.Goroutines()
+	switch sortBy {
+	case "":
+	case "stack":
+		gs = append([]*gocore.Goroutine(nil), gs...)
+		sort.SliceStable(gs, func(i, j int) bool {
+			return gs[i].StackInUse() > gs[j].StackInUse()
+		})
+	default:
+		exitf("can't sort goroutines by %q\n", sortBy)
+	}
+	if structured() {
+		if locals {
+			exitf("--locals needs --format text\n")
+		}
+		writeGoroutinesTable(gs)
+		return
+	}
+	for _, g := range gs {
+		fmt.Printf("G stacksize=%s inuse=%s\n", colored(colorSize, fmt.Sprintf("%x", g.Stack())), colored(colorSize, fmt.Sprintf("%x", g.StackInUse())))
 		for _, f := range g.Frames() {
 			pc := f.PC()
 			entry := f.Func().Entry()
@@ -456,9 +806,207 @@ func runGoroutines(cmd *cobra.Command, args []string) {
 			default:
 				adj = fmt.Sprintf("+%d", pc.Sub(entry))
 			}
-			fmt.Printf("  %016x %016x %s%s\n", f.Min(), f.Max(), f.Func().Name(), adj)
+			lfs := f.LogicalFrames()
+			for _, lf := range lfs[:len(lfs)-1] {
+				fmt.Printf("  %16s %16s %s (inlined)\n", "", "", lf.Name)
+			}
+			fmt.Printf("  %016x %016x %s%s\n", f.Min(), f.Max(), colored(colorType, f.Func().Name()), adj)
+			if !locals {
+				continue
+			}
+			for _, v := range f.Locals() {
+				if v.Addr != 0 {
+					fmt.Printf("    %016x %s %s\n", v.Addr, v.Name, c.TypeName(v.Type))
+				} else {
+					fmt.Printf("    %16s %s %s\n", "(registers)", v.Name, c.TypeName(v.Type))
+				}
+			}
+		}
+		if err := g.UnwindError(); err != nil {
+			fmt.Printf("  ... %v\n", err)
+		}
+	}
+}
+
+// writeGoroutinesTable writes the goroutines command's table: a row for
+// each logical frame of each goroutine, innermost first, or a row with
+// no frame for a goroutine with none.
+func writeGoroutinesTable(gs []*gocore.Goroutine) {
+	t := newTable(os.Stdout, "goroutine", "status", "stack_size", "stack_inuse", "unwind_error", "frame", "inlined", "func", "min", "max", "pc_offset")
+	for _, g := range gs {
+		var unwindErr any
+		if err := g.UnwindError(); err != nil {
+			unwindErr = err.Error()
+		}
+		row := func(frame, inlined, fn, lo, hi, off any) {
+			t.row(g.ID(), g.Status(), g.Stack(), g.StackInUse(), unwindErr, frame, inlined, fn, lo, hi, off)
+		}
+		frames := g.Frames()
+		if len(frames) == 0 {
+			row(nil, nil, nil, nil, nil, nil)
+		}
+		for i, f := range frames {
+			lfs := f.LogicalFrames()
+			for _, lf := range lfs[:len(lfs)-1] {
+				row(i, true, lf.Name, f.Min(), f.Max(), nil)
+			}
+			row(i, false, f.Func().Name(), f.Min(), f.Max(), f.PC().Sub(f.Func().Entry()))
+		}
+	}
+	t.flush()
+}
+
+func runGoroutine(cmd *cobra.Command, args []string) {
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		exitf("can't parse %q as a goroutine ID\n", args[0])
+	}
+	var g *gocore.Goroutine
+	for _, x := range c.Goroutines() {
+		if x.ID() == id {
+			g = x
+			break
+		}
+	}
+	if g == nil {
+		exitf("no goroutine %d\n", id)
+	}
+
+	state := g.Status()
+	if r := g.WaitReason(); r != "" {
+		state += ": " + r
+	}
+	fmt.Printf("goroutine %d [%s]:\n", g.ID(), state)
+	for _, f := range g.Frames() {
+		lfs := f.LogicalFrames()
+		for _, lf := range lfs[:len(lfs)-1] {
+			fmt.Printf("%s (inlined)\n", lf.Name)
+			fmt.Printf("\t%s:%d\n", lf.File, lf.Line)
+		}
+		lf := lfs[len(lfs)-1]
+		fmt.Printf("%s\n", lf.Name)
+		fmt.Printf("\t%s:%d +%#x sp=%#x\n", lf.File, lf.Line, f.PC().Sub(f.Func().Entry()), f.Min())
+		for _, v := range f.Locals() {
+			fmt.Printf("\t\t%s %s = %s\n", v.Name, c.TypeName(v.Type), formatValue(c, v.Type, c.ReadRoot(v.Root), 0))
+		}
+	}
+	if err := g.UnwindError(); err != nil {
+		fmt.Printf("...\n\t%v\n", err)
+	}
+}
+
+func runGlobals(cmd *cobra.Command, args []string) {
+	pattern, err := cmd.Flags().GetString("grep")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	var re *regexp.Regexp
+	if pattern != "" {
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			exitf("%v\n", err)
+		}
+	}
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	p := c.Process()
+	c.ForEachGlobal(func(name string, t *gocore.Type, a core.Address) bool {
+		if re != nil && !re.MatchString(name) {
+			return true
+		}
+		if !p.ReadableN(a, t.Size) {
+			fmt.Printf("%s %s = <unreadable at %#x>\n", name, c.TypeName(t), a)
+			return true
+		}
+		b := make([]byte, t.Size)
+		p.ReadAt(b, a)
+		fmt.Printf("%s %s = %s\n", name, c.TypeName(t), formatValue(c, t, b, 0))
+		return true
+	})
+}
+
+// formatValue returns a short description of the value of type t
+// whose bytes are b. Strings are read from the core. Aggregates are
+// only expanded a few levels deep.
+func formatValue(c *gocore.Process, t *gocore.Type, b []byte, depth int) string {
+	p := c.Process()
+	ptrSize := p.PtrSize()
+	order := c.ByteOrder()
+	// readUint reads the size-byte unsigned integer at b[off:].
+	readUint := func(off, size int64) uint64 {
+		switch size {
+		case 1:
+			return uint64(b[off])
+		case 2:
+			return uint64(order.Uint16(b[off:]))
+		case 4:
+			return uint64(order.Uint32(b[off:]))
+		}
+		return order.Uint64(b[off:])
+	}
+	word := func(off int64) uint64 {
+		return readUint(off, ptrSize)
+	}
+	switch t.Kind {
+	case gocore.KindBool:
+		return strconv.FormatBool(b[0] != 0)
+	case gocore.KindInt, gocore.KindUint:
+		v := readUint(0, t.Size)
+		if t.Kind == gocore.KindInt {
+			shift := 64 - 8*t.Size
+			return strconv.FormatInt(int64(v<<shift)>>shift, 10)
+		}
+		return strconv.FormatUint(v, 10)
+	case gocore.KindFloat:
+		if t.Size == 4 {
+			return strconv.FormatFloat(float64(math.Float32frombits(order.Uint32(b))), 'g', -1, 32)
+		}
+		return strconv.FormatFloat(math.Float64frombits(order.Uint64(b)), 'g', -1, 64)
+	case gocore.KindPtr, gocore.KindFunc:
+		return fmt.Sprintf("%#x", word(0))
+	case gocore.KindString:
+		const maxLen = 64
+		ptr, n := core.Address(word(0)), int64(word(ptrSize))
+		if n < 0 || !p.ReadableN(ptr, min(n, maxLen)) {
+			return fmt.Sprintf("string(%#x, %d)", ptr, n)
+		}
+		s := make([]byte, min(n, maxLen))
+		p.ReadAt(s, ptr)
+		if n > maxLen {
+			return fmt.Sprintf("%q...", s)
+		}
+		return fmt.Sprintf("%q", s)
+	case gocore.KindSlice:
+		return fmt.Sprintf("%s{ptr: %#x, len: %d, cap: %d}", c.TypeName(t), word(0), int64(word(ptrSize)), int64(word(2*ptrSize)))
+	case gocore.KindIface, gocore.KindEface:
+		return fmt.Sprintf("(%#x, %#x)", word(0), word(ptrSize))
+	case gocore.KindArray, gocore.KindStruct:
+		if depth > 2 {
+			return "{...}"
+		}
+		var parts []string
+		if t.Kind == gocore.KindArray {
+			for i := int64(0); i < t.Count; i++ {
+				if i == 4 {
+					parts = append(parts, "...")
+					break
+				}
+				parts = append(parts, formatValue(c, t.Elem, b[i*t.Elem.Size:], depth+1))
+			}
+		} else {
+			for _, f := range t.Fields {
+				parts = append(parts, f.Name+": "+formatValue(c, f.Type, b[f.Off:], depth+1))
+			}
 		}
+		return "{" + strings.Join(parts, ", ") + "}"
 	}
+	return fmt.Sprintf("%x", b)
 }
 
 func runHistogram(cmd *cobra.Command, args []string) {
@@ -466,10 +1014,31 @@ func runHistogram(cmd *cobra.Command, args []string) {
 	if err != nil {
 		exitf("%v\n", err)
 	}
+	retained, err := cmd.Flags().GetBool("retained")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	sortBy, err := cmd.Flags().GetString("sort")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	reverse, err := cmd.Flags().GetBool("reverse")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	switch sortBy {
+	case "bytes", "count", "size", "name":
+	default:
+		exitf("can't sort histogram by %q\n", sortBy)
+	}
 	_, c, err := readCore()
 	if err != nil {
 		exitf("%v\n", err)
 	}
+	if retained {
+		retainedHistogram(c, topN)
+		return
+	}
 	// Produce an object histogram (bytes per type).
 	type bucket struct {
 		name  string
@@ -489,8 +1058,28 @@ func runHistogram(cmd *cobra.Command, args []string) {
 		b.count++
 		return true
 	})
+	// Sort numbers largest first and names alphabetically, breaking
+	// ties by name.
+	key := func(b *bucket) int64 {
+		switch sortBy {
+		case "count":
+			return b.count
+		case "size":
+			return b.size
+		case "name":
+			return 0
+		}
+		return b.size * b.count
+	}
 	sort.Slice(buckets, func(i, j int) bool {
-		return buckets[i].size*buckets[i].count > buckets[j].size*buckets[j].count
+		bi, bj := buckets[i], buckets[j]
+		if reverse {
+			bi, bj = bj, bi
+		}
+		if ki, kj := key(bi), key(bj); ki != kj {
+			return ki > kj
+		}
+		return bi.name < bj.name
 	})
 
 	// report only top N if requested
@@ -498,73 +1087,269 @@ func runHistogram(cmd *cobra.Command, args []string) {
 		buckets = buckets[:topN]
 	}
 
+	if structured() {
+		t := newTable(os.Stdout, histogramCols...)
+		for _, e := range buckets {
+			t.row(e.count, e.size, e.count*e.size, nil, e.name)
+		}
+		t.flush()
+		return
+	}
 	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
-	fmt.Fprintf(t, "%s\t%s\t%s\t %s\n", "count", "size", "bytes", "type")
+	fmt.Fprintf(t, "%s\t%s\t%s\t %s\n", "count", "size", colored(colorSize, "bytes"), "type")
 	for _, e := range buckets {
-		fmt.Fprintf(t, "%d\t%d\t%d\t %s\n", e.count, e.size, e.count*e.size, e.name)
+		fmt.Fprintf(t, "%d\t%d\t%s\t %s\n", e.count, e.size, colored(colorSize, fmt.Sprint(e.count*e.size)), colored(colorType, e.name))
 	}
 	t.Flush()
 }
 
-func runBreakdown(cmd *cobra.Command, args []string) {
-	_, c, err := readCore()
-	if err != nil {
-		exitf("%v\n", err)
+// histogramCols are the columns of the histogram command's table, with
+// or without --retained. Without it, objects are grouped by type and
+// size, and retained is null; with it, by type alone, and size is null.
+var histogramCols = []string{"count", "size", "bytes", "retained", "type"}
+
+// retainedHistogram prints, for each type, the number and total size of
+// objects of that type and the number of bytes they retain.
+func retainedHistogram(c *gocore.Process, topN int) {
+	type bucket struct {
+		typ      *gocore.Type
+		count    int64
+		bytes    int64
+		retained int64
 	}
-	t := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', tabwriter.AlignRight)
-	all := c.Stats().Value
-	var printStat func(*gocore.Statistic, string)
-	printStat = func(s *gocore.Statistic, indent string) {
-		comment := ""
-		switch s.Name {
-		case "bss":
-			comment = "(grab bag, includes OS thread stacks, ...)"
-		case "manual spans":
-			comment = "(Go stacks)"
-		case "retained":
-			comment = "(kept for reuse by Go)"
-		case "released":
-			comment = "(given back to the OS)"
+	var buckets []*bucket
+	m := map[*gocore.Type]*bucket{}
+	c.ForEachObject(func(x gocore.Object) bool {
+		typ, _ := c.Type(x)
+		if typ == nil {
+			return true
 		}
-		fmt.Fprintf(t, "%s\t%d\t%6.2f%%\t %s\n", fmt.Sprintf("%-20s", indent+s.Name), s.Value, float64(s.Value)*100/float64(all), comment)
-		for c := range s.Children() {
-			printStat(c, indent+"  ")
+		b := m[typ]
+		if b == nil {
+			b = &bucket{typ: typ}
+			buckets = append(buckets, b)
+			m[typ] = b
+		}
+		b.count++
+		b.bytes += c.Size(x)
+		return true
+	})
+	for t, r := range c.RetainedByType() {
+		if b := m[t]; b != nil {
+			b.retained = r
 		}
 	}
-	printStat(c.Stats(), "")
-	t.Flush()
+	sort.Slice(buckets, func(i, j int) bool {
+		return buckets[i].retained > buckets[j].retained
+	})
+
+	// report only top N if requested
+	if topN > 0 && len(buckets) > topN {
+		buckets = buckets[:topN]
+	}
 
+	if structured() {
+		t := newTable(os.Stdout, histogramCols...)
+		for _, e := range buckets {
+			t.row(e.count, nil, e.bytes, e.retained, c.TypeName(e.typ))
+		}
+		t.flush()
+		return
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(t, "%s\t%s\t%s\t %s\n", "count", "bytes", colored(colorSize, "retained"), "type")
+	for _, e := range buckets {
+		fmt.Fprintf(t, "%d\t%d\t%s\t %s\n", e.count, e.bytes, colored(colorSize, fmt.Sprint(e.retained)), colored(colorType, c.TypeName(e.typ)))
+	}
+	t.Flush()
 }
 
-func runObjgraph(cmd *cobra.Command, args []string) {
+func runTypes(cmd *cobra.Command, args []string) {
 	_, c, err := readCore()
 	if err != nil {
 		exitf("%v\n", err)
 	}
+	// Count live instances of each type. An object holding
+	// an array of n values of a type counts n times.
+	live := map[*gocore.Type]int64{}
+	c.ForEachObject(func(x gocore.Object) bool {
+		if typ, repeat := c.Type(x); typ != nil {
+			live[typ] += repeat
+		}
+		return true
+	})
 
-	fname := args[0]
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(t, "%s\t%s\t %s\t %s\n", "live", "size", "kind", "type")
+	for _, typ := range c.Types() {
+		kind := strings.TrimPrefix(typ.Kind.String(), "Kind")
+		name := c.TypeName(typ)
+		if u := typ.Underlying(); u != typ {
+			name += " (" + c.TypeName(u) + ")"
+		}
+		fmt.Fprintf(t, "%d\t%d\t %s\t %s\n", live[typ], typ.Size, kind, name)
+	}
+	t.Flush()
+}
 
-	// Dump object graph to output file.
-	w, err := os.Create(fname)
+// percent returns n as a percentage of all, or 0 if all is 0.
+func percent(n, all int64) float64 {
+	if all == 0 {
+		return 0
+	}
+	return float64(n) * 100 / float64(all)
+}
+
+func runBreakdown(cmd *cobra.Command, args []string) {
+	_, c, err := readCore()
 	if err != nil {
-		panic(err)
+		exitf("%v\n", err)
 	}
-	fmt.Fprintf(w, "digraph {\n")
-	for k, r := range c.Globals() {
-		printed := false
-		c.ForEachRootPtr(r, func(i int64, y gocore.Object, j int64) bool {
-			if !printed {
-				fmt.Fprintf(w, "r%d [label=\"%s\n%s\",shape=hexagon]\n", k, r.Name, r.Type)
-				printed = true
-			}
-			fmt.Fprintf(w, "r%d -> o%x [label=\"%s\"", k, c.Addr(y), typeFieldName(r.Type, i))
-			if j != 0 {
-				fmt.Fprintf(w, " ,headlabel=\"+%d\"", j)
+	all := c.Stats().Value
+	if structured() {
+		// Name each statistic by its path from the root, like
+		// "all/heap/in use".
+		t := newTable(os.Stdout, "stat", "bytes", "percent")
+		var writeStat func(*gocore.Statistic, string)
+		writeStat = func(s *gocore.Statistic, path string) {
+			path += s.Name
+			t.row(path, s.Value, percent(s.Value, all))
+			for c := range s.Children() {
+				writeStat(c, path+"/")
 			}
-			fmt.Fprintf(w, "]\n")
+		}
+		writeStat(c.Stats(), "")
+		t.flush()
+		return
+	}
+	t := tabwriter.NewWriter(os.Stdout, 0, 8, 1, ' ', tabwriter.AlignRight)
+	var printStat func(*gocore.Statistic, string)
+	printStat = func(s *gocore.Statistic, indent string) {
+		comment := ""
+		switch s.Name {
+		case "other mmap":
+			comment = "(grab bag, includes OS thread stacks, mapped files, ...)"
+		case "manual spans":
+			comment = "(Go stacks)"
+		case "retained":
+			comment = "(kept for reuse by Go)"
+		case "released":
+			comment = "(given back to the OS)"
+		}
+		fmt.Fprintf(t, "%s\t%d\t%6.2f%%\t %s\n", fmt.Sprintf("%-20s", indent+s.Name), s.Value, percent(s.Value, all), comment)
+		for c := range s.Children() {
+			printStat(c, indent+"  ")
+		}
+	}
+	printStat(c.Stats(), "")
+	t.Flush()
+
+}
+
+func runSpans(cmd *cobra.Command, args []string) {
+	topN, err := cmd.Flags().GetInt("top")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+
+	// Count the live objects in each span. Allocated slots that aren't
+	// live hold garbage the next GC will free.
+	type span struct {
+		gocore.SpanInfo
+		live, liveBytes int64
+	}
+	var spans []span
+	live := map[uint8]int64{}
+	c.ForEachSpan(func(s gocore.SpanInfo) bool {
+		sp := span{SpanInfo: s}
+		c.ForEachObjectIn(s.Base, s.Base.Add(s.Size), func(x gocore.Object) bool {
+			sp.live++
+			sp.liveBytes += c.Size(x)
 			return true
 		})
+		live[s.Class] += sp.live
+		spans = append(spans, sp)
+		return true
+	})
+
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(t, "class\tsize\tspans\t%s\tslots\talloc\tfree\tlive\t\n", colored(colorSize, "bytes"))
+	for _, cs := range c.SpanStats() {
+		size := "large"
+		if cs.ElemSize != 0 {
+			size = fmt.Sprint(cs.ElemSize)
+		}
+		fmt.Fprintf(t, "%s\t%s\t%d\t%s\t%d\t%d\t%d\t%d\t\n", spanClassName(cs.Class), size, cs.Spans, colored(colorSize, fmt.Sprint(cs.Bytes)), cs.Slots, cs.Alloc, cs.Slots-cs.Alloc, live[cs.Class])
+	}
+	t.Flush()
+
+	// The least utilized spans: those with live objects, but the least
+	// live data for their size. A large object's span is all one object.
+	spans = slices.DeleteFunc(spans, func(s span) bool { return s.live == 0 || s.Class>>1 == 0 })
+	if topN <= 0 || len(spans) == 0 {
+		return
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		si, sj := spans[i], spans[j]
+		if ui, uj := si.liveBytes*sj.Size, sj.liveBytes*si.Size; ui != uj {
+			return ui < uj
+		}
+		return si.Base < sj.Base
+	})
+	spans = spans[:min(topN, len(spans))]
+	fmt.Println()
+	fmt.Println("least utilized spans:")
+	t = tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(t, "span\tclass\tsize\tslots\talloc\tlive\tlive bytes\tused\t\n")
+	for _, s := range spans {
+		fmt.Fprintf(t, "%x-%x\t%s\t%d\t%d\t%d\t%d\t%d\t%5.1f%%\t\n", s.Base, s.Base.Add(s.Size), spanClassName(s.Class), s.ElemSize, s.Slots, s.Alloc, s.live, s.liveBytes, float64(s.liveBytes)*100/float64(s.Size))
+	}
+	t.Flush()
+}
+
+// spanClassName returns the size class of the span class sc, followed by
+// "n" if its objects contain no pointers (noscan).
+func spanClassName(sc uint8) string {
+	name := fmt.Sprint(sc >> 1)
+	if sc&1 != 0 {
+		name += "n"
 	}
+	return name
+}
+
+func runObjgraph(cmd *cobra.Command, args []string) {
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+
+	fname := args[0]
+
+	// Dump object graph to output file.
+	w, err := os.Create(fname)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Fprintf(w, "digraph {\n")
+	k := -1
+	var last *gocore.Root
+	c.ForEachGlobalRootPtr(func(r *gocore.Root, i int64, y gocore.Object, j int64) bool {
+		if r != last {
+			k++
+			fmt.Fprintf(w, "r%d [label=\"%s\n%s\",shape=hexagon]\n", k, r.Name, c.TypeName(r.Type))
+			last = r
+		}
+		fmt.Fprintf(w, "r%d -> o%x [label=\"%s\"", k, c.Addr(y), r.Type.FieldNameAt(i))
+		if j != 0 {
+			fmt.Fprintf(w, " ,headlabel=\"%s\"", c.FieldPath(y, j))
+		}
+		fmt.Fprintf(w, "]\n")
+		return true
+	})
 	for _, g := range c.Goroutines() {
 		last := fmt.Sprintf("o%x", g.Addr())
 		for _, f := range g.Frames() {
@@ -574,9 +1359,9 @@ func runObjgraph(cmd *cobra.Command, args []string) {
 			last = frame
 			for _, r := range f.Roots() {
 				c.ForEachRootPtr(r, func(i int64, y gocore.Object, j int64) bool {
-					fmt.Fprintf(w, "%s -> o%x [label=\"%s%s\"", frame, c.Addr(y), r.Name, typeFieldName(r.Type, i))
+					fmt.Fprintf(w, "%s -> o%x [label=\"%s%s\"", frame, c.Addr(y), r.Name, r.Type.FieldNameAt(i))
 					if j != 0 {
-						fmt.Fprintf(w, " ,headlabel=\"+%d\"", j)
+						fmt.Fprintf(w, " ,headlabel=\"%s\"", c.FieldPath(y, j))
 					}
 					fmt.Fprintf(w, "]\n")
 					return true
@@ -589,9 +1374,9 @@ func runObjgraph(cmd *cobra.Command, args []string) {
 		size := c.Size(x)
 		fmt.Fprintf(w, "o%x [label=\"%s\\n%d\"]\n", addr, typeName(c, x), size)
 		c.ForEachPtr(x, func(i int64, y gocore.Object, j int64) bool {
-			fmt.Fprintf(w, "o%x -> o%x [label=\"%s\"", addr, c.Addr(y), fieldName(c, x, i))
+			fmt.Fprintf(w, "o%x -> o%x [label=\"%s\"", addr, c.Addr(y), c.FieldPath(x, i))
 			if j != 0 {
-				fmt.Fprintf(w, ",headlabel=\"+%d\"", j)
+				fmt.Fprintf(w, ",headlabel=\"%s\"", c.FieldPath(y, j))
 			}
 			fmt.Fprintf(w, "]\n")
 			return true
@@ -603,11 +1388,80 @@ func runObjgraph(cmd *cobra.Command, args []string) {
 	fmt.Fprintf(os.Stderr, "wrote the object graph to %q\n", fname)
 }
 
+func runExport(cmd *cobra.Command, args []string) {
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+
+	fname := args[0]
+	w, err := os.Create(fname)
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	if err := c.ExportGraph(w); err != nil {
+		exitf("%v\n", err)
+	}
+	if err := w.Close(); err != nil {
+		exitf("%v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote the object graph to %q\n", fname)
+}
+
+func runHeapdump(cmd *cobra.Command, args []string) {
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+
+	fname := args[0]
+	w, err := os.Create(fname)
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	if err := c.WriteHeapDump(w); err != nil {
+		exitf("%v\n", err)
+	}
+	if err := w.Close(); err != nil {
+		exitf("%v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote the heap dump to %q\n", fname)
+}
+
+func runTrace(cmd *cobra.Command, args []string) {
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+
+	data, err := c.TraceData()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	if data == nil {
+		exitf("no execution trace data in the core\n")
+	}
+	fname := args[0]
+	if err := os.WriteFile(fname, data, 0o666); err != nil {
+		exitf("%v\n", err)
+	}
+	fmt.Fprintf(os.Stderr, "wrote the execution trace to %q\n", fname)
+}
+
 func runObjects(cmd *cobra.Command, args []string) {
 	_, c, err := readCore()
 	if err != nil {
 		exitf("%v\n", err)
 	}
+	if structured() {
+		t := newTable(os.Stdout, "address", "size", "type")
+		c.ForEachObject(func(x gocore.Object) bool {
+			t.row(c.Addr(x), c.Size(x), typeName(c, x))
+			return true
+		})
+		t.flush()
+		return
+	}
 	c.ForEachObject(func(x gocore.Object) bool {
 		fmt.Printf("%16x %s\n", c.Addr(x), typeName(c, x))
 		return true
@@ -615,88 +1469,340 @@ func runObjects(cmd *cobra.Command, args []string) {
 
 }
 
+func runStringdump(cmd *cobra.Command, args []string) {
+	minLen, err := cmd.Flags().GetInt("min-len")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	pattern, err := cmd.Flags().GetString("grep")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	var re *regexp.Regexp
+	if pattern != "" {
+		re, err = regexp.Compile(pattern)
+		if err != nil {
+			exitf("%v\n", err)
+		}
+	}
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+
+	// Only this much of each string is read and printed.
+	const maxLen = 1024
+
+	p := c.Process()
+	type key struct {
+		ptr core.Address
+		n   int64
+	}
+	seen := map[key]bool{}
+	c.ForEachObject(func(x gocore.Object) bool {
+		typ, repeat := c.Type(x)
+		if typ == nil {
+			return true
+		}
+		for i := int64(0); i < repeat; i++ {
+			forEachString(typ, c.Addr(x).Add(i*typ.Size), func(a core.Address) {
+				ptr := p.ReadPtr(a)
+				n := p.ReadInt(a.Add(p.PtrSize()))
+				if ptr == 0 || n <= 0 || n < int64(minLen) || seen[key{ptr, n}] {
+					return
+				}
+				seen[key{ptr, n}] = true
+				b := make([]byte, min(n, maxLen))
+				if !p.ReadableN(ptr, int64(len(b))) {
+					return
+				}
+				p.ReadAt(b, ptr)
+				if re != nil && !re.Match(b) {
+					return
+				}
+				var more string
+				if n > maxLen {
+					more = fmt.Sprintf("... (%d more bytes)", n-maxLen)
+				}
+				fmt.Printf("%16x %q%s\n", ptr, b, more)
+			})
+		}
+		return true
+	})
+}
+
+// forEachString calls fn with the address of each string header and
+// []byte slice header in a value of type t at address a.
+func forEachString(t *gocore.Type, a core.Address, fn func(core.Address)) {
+	switch t.Kind {
+	case gocore.KindString:
+		fn(a)
+	case gocore.KindSlice:
+		if t.Elem != nil && t.Elem.Kind == gocore.KindUint && t.Elem.Size == 1 {
+			fn(a)
+		}
+	case gocore.KindArray:
+		for i := int64(0); i < t.Count; i++ {
+			forEachString(t.Elem, a.Add(i*t.Elem.Size), fn)
+		}
+	case gocore.KindStruct:
+		for _, f := range t.Fields {
+			forEachString(f.Type, a.Add(f.Off), fn)
+		}
+	}
+}
+
 func runReachable(cmd *cobra.Command, args []string) {
+	all, err := cmd.Flags().GetBool("all")
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	maxPaths, err := cmd.Flags().GetInt("max")
+	if err != nil {
+		exitf("%v\n", err)
+	}
 	_, c, err := readCore()
 	if err != nil {
 		exitf("%v\n", err)
 	}
 	n, err := strconv.ParseInt(args[0], 16, 64)
 	if err != nil {
-		exitf("can't parse %q as an object address\n", args[1])
+		exitf("can't parse %q as an object address\n", args[0])
 	}
 	a := core.Address(n)
 	obj, _ := c.FindObject(a)
 	if obj == 0 {
-		exitf("can't find object at address %s\n", args[1])
+		exitf("can't find object at address %s\n", args[0])
+	}
+
+	if all {
+		// Search the whole graph backwards, and print the shortest
+		// path from each root that can reach the object, nearest first.
+		// A root may point to the object, or to objects on the way to
+		// it, more than once; the first path found from it is the shortest.
+		depth := map[gocore.Object]int{obj: 0}
+		q := []gocore.Object{obj}
+		var paths []rootPath
+		seen := map[*gocore.Root]bool{}
+		for len(q) > 0 {
+			z := q[0]
+			q = q[1:]
+			c.ForEachReversePtr(z, func(x gocore.Object, r *gocore.Root, i, j int64) bool {
+				if r != nil {
+					if !seen[r] {
+						seen[r] = true
+						paths = append(paths, rootPath{root: r, off: i, y: z})
+					}
+					return true
+				}
+				if _, ok := depth[x]; !ok {
+					depth[x] = depth[z] + 1
+					q = append(q, x)
+				}
+				return true
+			})
+		}
+		if len(paths) == 0 {
+			panic("can't find a root that can reach the object")
+		}
+		for k, path := range paths {
+			if maxPaths > 0 && k == maxPaths {
+				fmt.Printf("... and %d more paths; use --max to see more\n", len(paths)-k)
+				break
+			}
+			if k > 0 {
+				fmt.Println()
+			}
+			printPath(c, path, obj, depth)
+			if path.root.Conservative() {
+				fmt.Printf("warning: %s is scanned conservatively; the pointer may be dead\n", path.root.Frame.Func().Name())
+			}
+		}
+		return
 	}
 
 	// Breadth-first search backwards until we reach a root.
-	type hop struct {
-		i int64         // offset in "from" object (the key in the path map) where the pointer is
-		x gocore.Object // the "to" object
-		j int64         // the offset in the "to" object
-	}
+	// Prefer roots whose pointers are known to be live. Settle for one
+	// in a conservatively scanned frame only if there is no other.
 	depth := map[gocore.Object]int{}
 	depth[obj] = 0
 	q := []gocore.Object{obj}
-	done := false
-	for !done {
+	var path rootPath
+	for path.root == nil || path.root.Conservative() {
 		if len(q) == 0 {
+			if path.root != nil {
+				break
+			}
 			panic("can't find a root that can reach the object")
 		}
-		y := q[0]
+		z := q[0]
 		q = q[1:]
-		c.ForEachReversePtr(y, func(x gocore.Object, r *gocore.Root, i, j int64) bool {
+		c.ForEachReversePtr(z, func(x gocore.Object, r *gocore.Root, i, j int64) bool {
 			if r != nil {
 				// found it.
-				if r.Frame == nil {
-					// Print global
-					fmt.Printf("%s", r.Name)
-				} else {
-					// Print stack up to frame in question.
-					var frames []*gocore.Frame
-					for f := r.Frame.Parent(); f != nil; f = f.Parent() {
-						frames = append(frames, f)
-					}
-					for k := len(frames) - 1; k >= 0; k-- {
-						fmt.Printf("%s\n", frames[k].Func().Name())
-					}
-					// Print frame + variable in frame.
-					fmt.Printf("%s.%s", r.Frame.Func().Name(), r.Name)
-				}
-				fmt.Printf("%s → \n", typeFieldName(r.Type, i))
-
-				z := y
-				for {
-					fmt.Printf("%x %s", c.Addr(z), typeName(c, z))
-					if z == obj {
-						fmt.Println()
-						break
-					}
-					// Find an edge out of z which goes to an object
-					// closer to obj.
-					c.ForEachPtr(z, func(i int64, w gocore.Object, j int64) bool {
-						if d, ok := depth[w]; ok && d < depth[z] {
-							fmt.Printf(" %s → %s", objField(c, z, i), objRegion(c, w, j))
-							z = w
-							return false
-						}
-						return true
-					})
-					fmt.Println()
+				if path.root == nil || !r.Conservative() {
+					path = rootPath{root: r, off: i, y: z}
 				}
-				done = true
-				return false
+				return path.root.Conservative()
 			}
 			if _, ok := depth[x]; ok {
 				// we already found a shorter path to this object.
 				return true
 			}
-			depth[x] = depth[y] + 1
+			depth[x] = depth[z] + 1
 			q = append(q, x)
 			return true
 		})
 	}
+
+	printPath(c, path, obj, depth)
+	if path.root.Conservative() {
+		fmt.Printf("warning: %s is scanned conservatively, and there is no other path; the pointer may be dead\n", path.root.Frame.Func().Name())
+	}
+}
+
+// A rootPath is the start of a path from a root to an object.
+type rootPath struct {
+	root *gocore.Root
+	off  int64         // offset in root where the pointer is
+	y    gocore.Object // the object root points to
+}
+
+// printPath prints the path from path's root to obj, following edges to
+// objects closer to obj, as given by depth, their distance from it.
+func printPath(c *gocore.Process, path rootPath, obj gocore.Object, depth map[gocore.Object]int) {
+	root := path.root
+	if root.Frame == nil {
+		// Print global
+		fmt.Printf("%s", root.Name)
+	} else {
+		// Print stack up to frame in question.
+		var frames []*gocore.Frame
+		for f := root.Frame.Parent(); f != nil; f = f.Parent() {
+			frames = append(frames, f)
+		}
+		for k := len(frames) - 1; k >= 0; k-- {
+			fmt.Printf("%s\n", frames[k].Func().Name())
+		}
+		// Print frame + variable in frame.
+		fmt.Printf("%s.%s", root.Frame.Func().Name(), root.Name)
+	}
+	fmt.Printf("%s → \n", root.Type.FieldNameAt(path.off))
+
+	z := path.y
+	for {
+		fmt.Printf("%x %s", c.Addr(z), typeName(c, z))
+		if z == obj {
+			fmt.Println()
+			break
+		}
+		// Find an edge out of z which goes to an object
+		// closer to obj.
+		c.ForEachPtr(z, func(i int64, w gocore.Object, j int64) bool {
+			if d, ok := depth[w]; ok && d < depth[z] {
+				fmt.Printf(" %s → ", c.FieldPath(z, i))
+				if j != 0 {
+					fmt.Printf("%s", c.FieldPath(w, j))
+				}
+				z = w
+				return false
+			}
+			return true
+		})
+		fmt.Println()
+	}
+}
+
+func runRetains(cmd *cobra.Command, args []string) {
+	_, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	n, err := strconv.ParseInt(args[0], 16, 64)
+	if err != nil {
+		exitf("can't parse %q as an object address\n", args[0])
+	}
+	obj, _ := c.FindObject(core.Address(n))
+	if obj == 0 {
+		exitf("can't find object at address %s\n", args[0])
+	}
+
+	var count, total int64
+	t := tabwriter.NewWriter(os.Stdout, 0, 0, 1, ' ', tabwriter.AlignRight)
+	fmt.Fprintf(t, "%s\t%s\t %s\n", "address", "size", "type")
+	c.ForEachReachable(obj, func(x gocore.Object) bool {
+		fmt.Fprintf(t, "%x\t%d\t %s\n", c.Addr(x), c.Size(x), typeName(c, x))
+		count++
+		total += c.Size(x)
+		return true
+	})
+	t.Flush()
+	fmt.Printf("%d objects, %d bytes\n", count, total)
+}
+
+func runWhatis(cmd *cobra.Command, args []string) {
+	p, c, err := readCore()
+	if err != nil {
+		exitf("%v\n", err)
+	}
+	n, err := strconv.ParseUint(args[0], 16, 64)
+	if err != nil {
+		exitf("can't parse %q as an address\n", args[0])
+	}
+	a := core.Address(n)
+
+	// rootName returns the name of the root in rs containing a, if any.
+	rootName := func(rs []*gocore.Root) string {
+		for _, r := range rs {
+			if r.HasAddress() && r.Addr() <= a && a < r.Addr().Add(r.Type.Size) {
+				return r.Name + r.Type.FieldNameAt(a.Sub(r.Addr()))
+			}
+		}
+		return ""
+	}
+
+	kind, g, f := c.Classify(a)
+	switch kind {
+	case gocore.AddrHeap:
+		x, off := c.FindObject(a)
+		if x == 0 {
+			fmt.Printf("%x: heap, not in a live object\n", a)
+			return
+		}
+		fmt.Printf("%x: heap object %x+%d, type %s, size %d\n", a, c.Addr(x), off, typeName(c, x), c.Size(x))
+		if off != 0 {
+			fmt.Printf("  field %s\n", c.FieldPath(x, off))
+		}
+		s := c.ObjectSpan(x)
+		fmt.Printf("  span %x, class %d, %d of %d slots allocated\n", s.Base, s.Class, s.Alloc, s.Slots)
+	case gocore.AddrStack:
+		fmt.Printf("%x: stack of goroutine %d\n", a, g.ID())
+		if f == nil {
+			fmt.Printf("  not in an active frame\n")
+			return
+		}
+		fmt.Printf("  frame %s [%x,%x)\n", f.Func().Name(), f.Min(), f.Max())
+		if name := rootName(f.Roots()); name != "" {
+			fmt.Printf("  variable %s\n", name)
+		}
+	case gocore.AddrGlobal:
+		fmt.Printf("%x: global\n", a)
+		if name := rootName(c.Globals()); name != "" {
+			fmt.Printf("  variable %s\n", name)
+		}
+	default:
+		if !p.Readable(a) {
+			fmt.Printf("%x: unmapped\n", a)
+			return
+		}
+		for _, m := range p.Mappings() {
+			if m.Min() <= a && a < m.Max() {
+				file, off := m.Source()
+				fmt.Printf("%x: unknown, in mapping %x-%x %s %s@%x\n", a, m.Min(), m.Max(), m.Perm(), file, off)
+				break
+			}
+		}
+	}
 }
 
 // httpServer is the singleton http server, initialized by
@@ -728,21 +1834,39 @@ func runHTML(cmd *cobra.Command, args []string) {
 }
 
 func runRead(cmd *cobra.Command, args []string) {
-	p, _, err := readCore()
+	p, c, err := readCore()
 	if err != nil {
 		exitf("%v\n", err)
 	}
-	n, err := strconv.ParseInt(args[0], 16, 64)
-	if err != nil {
-		exitf("can't parse %q as an object address\n", args[1])
+	a, n := core.Address(0), int64(256)
+	found := false
+	c.ForEachGlobal(func(name string, t *gocore.Type, addr core.Address) bool {
+		if name == args[0] {
+			a, n, found = addr, t.Size, true
+		}
+		return !found
+	})
+	if !found {
+		syms, _ := p.Symbols()
+		a, found = syms[args[0]]
+	}
+	if !found {
+		// Functions are known even without a symbol table.
+		if f := c.FindFuncByName(args[0]); f != nil {
+			a, found = f.Entry(), true
+		}
 	}
-	a := core.Address(n)
-	if len(args) < 2 {
-		n = 256
-	} else {
+	if !found {
+		x, err := strconv.ParseInt(args[0], 16, 64)
+		if err != nil {
+			exitf("can't parse %q as an address, symbol, or global\n", args[0])
+		}
+		a = core.Address(x)
+	}
+	if len(args) == 2 {
 		n, err = strconv.ParseInt(args[1], 10, 64)
 		if err != nil {
-			exitf("can't parse %q as a byte count\n", args[2])
+			exitf("can't parse %q as a byte count\n", args[1])
 		}
 	}
 	if !p.ReadableN(a, n) {
@@ -762,6 +1886,38 @@ func runRead(cmd *cobra.Command, args []string) {
 	fmt.Println()
 }
 
+// shortTypeName returns the type name s with each package's import
+// path shortened to its last element, so that
+// "map[string]*github.com/foo/bar.Baz" becomes "map[string]*bar.Baz".
+// Struct tags are left alone.
+func shortTypeName(s string) string {
+	var b strings.Builder
+	start := 0 // start of the current qualified identifier
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '/':
+			start = i + 1
+		case '"':
+			// A quoted struct tag; copy it as is.
+			j := i + 1
+			for j < len(s) && s[j] != '"' {
+				if s[j] == '\\' {
+					j++
+				}
+				j++
+			}
+			i = min(j, len(s)-1)
+			b.WriteString(s[start : i+1])
+			start = i + 1
+		case '[', ']', '*', '(', ')', '{', '}', ',', ';', ' ':
+			b.WriteString(s[start : i+1])
+			start = i + 1
+		}
+	}
+	b.WriteString(s[start:])
+	return b.String()
+}
+
 // typeName returns a string representing the type of this object.
 func typeName(c *gocore.Process, x gocore.Object) string {
 	size := c.Size(x)
@@ -769,7 +1925,7 @@ func typeName(c *gocore.Process, x gocore.Object) string {
 	if typ == nil {
 		return fmt.Sprintf("unk%d", size)
 	}
-	name := typ.String()
+	name := c.TypeName(typ)
 	n := size / typ.Size
 	if n > 1 {
 		if repeat < n {
@@ -781,75 +1937,6 @@ func typeName(c *gocore.Process, x gocore.Object) string {
 	return name
 }
 
-// fieldName returns the name of the field at offset off in x.
-func fieldName(c *gocore.Process, x gocore.Object, off int64) string {
-	size := c.Size(x)
-	typ, repeat := c.Type(x)
-	if typ == nil {
-		return fmt.Sprintf("f%d", off)
-	}
-	n := size / typ.Size
-	i := off / typ.Size
-	if i == 0 && repeat == 1 {
-		// Probably a singleton object, no need for array notation.
-		return typeFieldName(typ, off)
-	}
-	if i >= n {
-		// Partial space at the end of the object - the type can't be complete.
-		return fmt.Sprintf("f%d", off)
-	}
-	q := ""
-	if i >= repeat {
-		// Past the known repeat section, add a ? because we're not sure about the type.
-		q = "?"
-	}
-	return fmt.Sprintf("[%d]%s%s", i, typeFieldName(typ, off-i*typ.Size), q)
-}
-
-// typeFieldName returns the name of the field at offset off in t.
-func typeFieldName(t *gocore.Type, off int64) string {
-	switch t.Kind {
-	case gocore.KindBool, gocore.KindInt, gocore.KindUint, gocore.KindFloat:
-		return ""
-	case gocore.KindComplex:
-		if off == 0 {
-			return ".real"
-		}
-		return ".imag"
-	case gocore.KindIface, gocore.KindEface:
-		if off == 0 {
-			return ".type"
-		}
-		return ".data"
-	case gocore.KindPtr, gocore.KindFunc:
-		return ""
-	case gocore.KindString:
-		if off == 0 {
-			return ".ptr"
-		}
-		return ".len"
-	case gocore.KindSlice:
-		if off == 0 {
-			return ".ptr"
-		}
-		if off <= t.Size/2 {
-			return ".len"
-		}
-		return ".cap"
-	case gocore.KindArray:
-		s := t.Elem.Size
-		i := off / s
-		return fmt.Sprintf("[%d]%s", i, typeFieldName(t.Elem, off-i*s))
-	case gocore.KindStruct:
-		for _, f := range t.Fields {
-			if f.Off <= off && off < f.Off+f.Type.Size {
-				return "." + f.Name + typeFieldName(f.Type, off-f.Off)
-			}
-		}
-	}
-	return ".???"
-}
-
 func startProfile() {
 	if cfg.cpuprof != "" {
 		f, err := os.Create(cfg.cpuprof)
@@ -869,6 +1956,7 @@ func endProfile() {
 }
 
 func exitf(format string, args ...interface{}) {
+	stopOutput()
 	fmt.Fprintf(os.Stderr, format, args...)
 	os.Exit(1)
 }
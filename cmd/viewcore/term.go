@@ -0,0 +1,119 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+//go:build !aix && !plan9 && !wasm
+
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// ANSI colors for the key columns of command output.
+const (
+	colorType = "36" // cyan: type and function names
+	colorSize = "33" // yellow: byte counts
+)
+
+var (
+	// colorize is whether to color output. It is decided before
+	// starting the pager, which replaces os.Stdout with a pipe.
+	colorize bool
+
+	pager       *exec.Cmd
+	pagerStdout *os.File // the real os.Stdout, while pager runs
+)
+
+// isTerminal reports whether f is a terminal.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// progressBar returns a gocore.Options.Progress callback that shows on
+// stderr how far reading the core has got, or nil if stderr isn't a
+// terminal. Call clearProgress when Core returns.
+func progressBar() func(phase string, done, total int64) {
+	if !isTerminal(os.Stderr) {
+		return nil
+	}
+	return func(phase string, done, total int64) {
+		const width = 40
+		var frac float64
+		if total > 0 {
+			frac = float64(done) / float64(total)
+		}
+		n := int(frac * width)
+		fmt.Fprintf(os.Stderr, "\rreading core: %-10s [%s%s] %3.0f%%", phase, strings.Repeat("=", n), strings.Repeat(" ", width-n), frac*100)
+	}
+}
+
+// clearProgress erases the line drawn by progressBar's callback.
+func clearProgress() {
+	fmt.Fprint(os.Stderr, "\r\x1b[K")
+}
+
+// colored returns s in the given ANSI color if output is colored,
+// and s itself otherwise. Within a tabwriter column, color every cell
+// (or none), so that they all have the same invisible width.
+func colored(color, s string) string {
+	if !colorize {
+		return s
+	}
+	return "\x1b[" + color + "m" + s + "\x1b[0m"
+}
+
+// startOutput prepares stdout for a command: it decides whether to
+// color output, and with --page starts $PAGER (default less) and
+// points os.Stdout at it. Output that isn't to a terminal is neither
+// colored nor paged. Color is also off if NO_COLOR is set
+// (see https://no-color.org).
+func startOutput(cmd *cobra.Command, args []string) {
+	if !cmd.HasParent() {
+		return // the interactive shell; its commands are paged one by one
+	}
+	tty := isTerminal(os.Stdout)
+	colorize = tty && os.Getenv("NO_COLOR") == ""
+	if !cfg.page || !tty {
+		return
+	}
+	argv := strings.Fields(os.Getenv("PAGER"))
+	if len(argv) == 0 {
+		// -F: exit if the output fits on one screen.
+		// -R: show colors. -X: leave the output on the screen.
+		argv = []string{"less", "-FRX"}
+	}
+	r, w, err := os.Pipe()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "can't start pager: %v\n", err)
+		return
+	}
+	p := exec.Command(argv[0], argv[1:]...)
+	p.Stdin, p.Stdout, p.Stderr = r, os.Stdout, os.Stderr
+	err = p.Start()
+	r.Close()
+	if err != nil {
+		w.Close()
+		fmt.Fprintf(os.Stderr, "can't start pager: %v\n", err)
+		return
+	}
+	pager, pagerStdout = p, os.Stdout
+	os.Stdout = w
+}
+
+// stopOutput undoes startOutput, waiting for the user to quit the pager.
+func stopOutput() {
+	if pager == nil {
+		return
+	}
+	os.Stdout.Close()
+	os.Stdout = pagerStdout
+	pager.Wait()
+	pager = nil
+}
@@ -38,3 +38,13 @@ func (a Address) Min(b Address) Address {
 func (a Address) Align(x int64) Address {
 	return (a + Address(x) - 1) & ^(Address(x) - 1)
 }
+
+// An AddressRange is the half-open range of addresses [Min, Max).
+type AddressRange struct {
+	Min, Max Address
+}
+
+// Size returns the number of bytes in r.
+func (r AddressRange) Size() int64 {
+	return r.Max.Sub(r.Min)
+}
@@ -7,7 +7,15 @@
 package core
 
 import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
 	"testing"
 )
 
@@ -34,6 +42,45 @@ func loadExample(t *testing.T, useExePath bool) *Process {
 	return p
 }
 
+// TestBaseList makes sure files in the core are found when base lists
+// several directories.
+func TestBaseList(t *testing.T) {
+	base := filepath.Join(t.TempDir(), "missing") + string(os.PathListSeparator) + "testdata"
+	p, err := Core("testdata/core", base, "")
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	want := filepath.Join("testdata", "tmp", "test")
+	for _, m := range p.Mappings() {
+		if name, _ := m.Source(); name == want {
+			return
+		}
+	}
+	t.Errorf("no mapping read from %s", want)
+}
+
+// TestDataSource checks which mappings are read from the core.
+func TestDataSource(t *testing.T) {
+	p := loadExample(t, false)
+	for _, m := range p.Mappings() {
+		fromCore, stale := m.DataSource()
+		if name, _ := m.Source(); fromCore != (name == "testdata/core") {
+			t.Errorf("mapping %x-%x read from %s: fromCore=%t", m.Min(), m.Max(), name, fromCore)
+		}
+		if stale != (!fromCore && m.Perm()&Write != 0) {
+			t.Errorf("mapping %x-%x: stale=%t, want %t", m.Min(), m.Max(), stale, !stale)
+		}
+	}
+	s, err := p.Symbols()
+	if err != nil {
+		t.Fatalf("can't read symbols: %s", err)
+	}
+	// The text is read from the executable, not the core, but it is read-only.
+	if fromCore, stale := p.pageTable.findMapping(s["main.main"]).DataSource(); fromCore || stale {
+		t.Errorf("text mapping: fromCore=%t stale=%t, want false, false", fromCore, stale)
+	}
+}
+
 // TestMappings makes sure we can find and load some data.
 func TestMappings(t *testing.T) {
 	test := func(t *testing.T, useExePath bool) {
@@ -122,6 +169,32 @@ func TestThread(t *testing.T) {
 	}
 }
 
+func TestThreadARM(t *testing.T) {
+	// A 32-bit arm prstatus_t: pr_pid at 24, then r0-r15, cpsr and
+	// orig_r0 at 72, then pr_fpvalid.
+	desc := make([]byte, 148)
+	binary.LittleEndian.PutUint32(desc[24:], 1234)
+	for i := range 18 {
+		binary.LittleEndian.PutUint32(desc[72+4*i:], uint32(0x1000+i))
+	}
+	meta := metadata{arch: "arm", ptrSize: 4, logPtrSize: 2, byteOrder: binary.LittleEndian, littleEndian: true}
+	threads := readThreads(meta, noteMap{elf.NT_PRSTATUS: {desc, desc[:100]}})
+	if len(threads) != 1 {
+		t.Fatalf("got %d threads, want 1 (the truncated note should be skipped)", len(threads))
+	}
+	thr := threads[0]
+	if thr.Pid() != 1234 {
+		t.Errorf("Pid() = %d, want 1234", thr.Pid())
+	}
+	if thr.SP() != 0x1000+13 || thr.PC() != 0x1000+15 {
+		t.Errorf("SP(), PC() = %x, %x, want %x, %x", thr.SP(), thr.PC(), 0x1000+13, 0x1000+15)
+	}
+	regs := thr.Regs()
+	if len(regs) != 18 || regs[0] != (Register{"r0", 0x1000}) || regs[16] != (Register{"cpsr", 0x1000 + 16}) {
+		t.Errorf("Regs() = %v, want r0-r15, cpsr and orig_r0", regs)
+	}
+}
+
 func TestArgs(t *testing.T) {
 	p := loadExample(t, true)
 	if got := p.Args(); got != "./test" {
@@ -129,3 +202,342 @@ func TestArgs(t *testing.T) {
 		t.Errorf("Args() = %q, want './test'", got)
 	}
 }
+
+func TestReadCString(t *testing.T) {
+	// Two abutting mappings, followed by a hole.
+	m1 := &Mapping{min: 1 * pageSize, max: 2 * pageSize, perm: Read, contents: make([]byte, pageSize)}
+	m2 := &Mapping{min: 2 * pageSize, max: 3 * pageSize, perm: Read, contents: make([]byte, pageSize)}
+	var p Process
+	for _, m := range []*Mapping{m1, m2} {
+		if err := p.pageTable.addMapping(m); err != nil {
+			t.Fatal(err)
+		}
+		for i := range m.contents {
+			m.contents[i] = 'a'
+		}
+	}
+	copy(m1.contents, "hello\x00")
+	m2.contents[10] = 0
+
+	tests := []struct {
+		a    Address
+		max  int64
+		want int64 // length of result
+	}{
+		{1 * pageSize, 100, 5},                           // terminated
+		{1 * pageSize, 3, 3},                             // truncated by max
+		{1*pageSize + 6, 1 << 20, int64(pageSize + 4)},   // spans both mappings
+		{2*pageSize + 11, 1 << 20, int64(pageSize - 11)}, // runs off the end
+		{4 * pageSize, 100, 0},                           // unmapped
+	}
+	for _, test := range tests {
+		if got := p.ReadCString(test.a, test.max); int64(len(got)) != test.want {
+			t.Errorf("ReadCString(%#x, %d) returned %d bytes, want %d", test.a, test.max, len(got), test.want)
+		}
+	}
+	if got := p.ReadCString(1*pageSize, 100); got != "hello" {
+		t.Errorf("ReadCString = %q, want %q", got, "hello")
+	}
+}
+
+func TestReaderAt(t *testing.T) {
+	// Two abutting mappings, then a hole, then another mapping.
+	var p Process
+	for i, min := range []Address{1 * pageSize, 2 * pageSize, 4 * pageSize} {
+		m := &Mapping{min: min, max: min + pageSize, perm: Read, contents: bytes.Repeat([]byte{byte(i + 1)}, int(pageSize))}
+		if err := p.pageTable.addMapping(m); err != nil {
+			t.Fatal(err)
+		}
+		p.memory.mappings = append(p.memory.mappings, m)
+	}
+	r := p.ReaderAt()
+	const ps = int64(pageSize)
+
+	tests := []struct {
+		off     int64
+		n       int
+		want    int
+		wantErr bool
+		wantEOF bool
+	}{
+		{1 * ps, 10, 10, false, false},   // within a mapping
+		{2*ps - 5, 10, 10, false, false}, // spans two mappings
+		{3*ps - 5, 10, 5, true, false},   // runs into the hole
+		{3 * ps, 10, 0, true, false},     // in the hole
+		{5*ps - 5, 10, 5, true, true},    // runs off the end
+		{-1, 10, 0, true, false},         // not an address
+	}
+	for _, test := range tests {
+		b := make([]byte, test.n)
+		n, err := r.ReadAt(b, test.off)
+		if n != test.want || (err != nil) != test.wantErr || (err == io.EOF) != test.wantEOF {
+			t.Errorf("ReadAt(%d bytes, %#x) = %d, %v, want %d bytes, error %v, EOF %v", test.n, test.off, n, err, test.want, test.wantErr, test.wantEOF)
+		}
+	}
+
+	// Standard library readers work on top of it.
+	var x uint16
+	if err := binary.Read(io.NewSectionReader(r, 2*ps-1, 2), binary.LittleEndian, &x); err != nil || x != 0x0201 {
+		t.Errorf("binary.Read = %#x, %v, want 0x0201, nil", x, err)
+	}
+}
+
+func TestClose(t *testing.T) {
+	p := loadExample(t, false)
+	var files []*os.File
+	mapped := 0
+	for _, m := range p.Mappings() {
+		if m.f != nil {
+			files = append(files, m.f)
+		}
+		if m.mapped != nil {
+			mapped++
+		}
+	}
+	if mapped == 0 {
+		t.Fatal("no mappings are memory mapped")
+	}
+	if err := p.Close(); err != nil {
+		t.Fatalf("Close: %v", err)
+	}
+	for _, f := range files {
+		if f.Fd() != ^uintptr(0) {
+			t.Errorf("%s is still open after Close", f.Name())
+		}
+	}
+	for _, m := range p.Mappings() {
+		if m.mapped != nil || m.contents != nil {
+			t.Errorf("mapping %x-%x still has contents after Close", m.min, m.max)
+		}
+	}
+	if err := p.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+}
+
+func TestReadFileMappings(t *testing.T) {
+	want := []namedMapping{
+		{min: 0x1000, max: 0x3000, f: "/bin/a", off: 0},
+		{min: 0x3000, max: 0x4000, f: "/lib/b.so", off: 0x2000},
+	}
+	for _, ptrSize := range []int64{4, 8} {
+		t.Run(fmt.Sprint(ptrSize*8), func(t *testing.T) {
+			meta := metadata{ptrSize: ptrSize, byteOrder: binary.LittleEndian}
+			var desc []byte
+			word := func(v uint64) {
+				if ptrSize == 4 {
+					desc = binary.LittleEndian.AppendUint32(desc, uint32(v))
+				} else {
+					desc = binary.LittleEndian.AppendUint64(desc, v)
+				}
+			}
+			const pageSize = 0x1000
+			word(uint64(len(want)))
+			word(pageSize)
+			for _, m := range want {
+				word(uint64(m.min))
+				word(uint64(m.max))
+				word(uint64(m.off) / pageSize)
+			}
+			for _, m := range want {
+				desc = append(desc, m.f...)
+				desc = append(desc, 0)
+			}
+			got := readFileMappings(meta, noteMap{_NT_FILE: {desc}})
+			if !reflect.DeepEqual(got, want) {
+				t.Errorf("readFileMappings = %+v, want %+v", got, want)
+			}
+		})
+	}
+}
+
+func TestParseNotes(t *testing.T) {
+	var b []byte
+	note := func(name string, typ elf.NType, desc string) {
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(name)+1))
+		b = binary.LittleEndian.AppendUint32(b, uint32(len(desc)))
+		b = binary.LittleEndian.AppendUint32(b, uint32(typ))
+		b = append(b, name...)
+		for b = append(b, 0); len(b)%4 != 0; {
+			b = append(b, 0)
+		}
+		for b = append(b, desc...); len(b)%4 != 0; {
+			b = append(b, 0)
+		}
+	}
+	note("CORE", elf.NT_PRSTATUS, "thread1")
+	note("LINUX", elf.NType(0x202), "xstate")
+	note("CORE", elf.NT_PRSTATUS, "thread2")
+	want := noteMap{elf.NT_PRSTATUS: {[]byte("thread1"), []byte("thread2")}}
+
+	notes := noteMap{}
+	if err := parseNotes(notes, b, binary.LittleEndian); err != nil {
+		t.Errorf("parseNotes: %v", err)
+	}
+	if !reflect.DeepEqual(notes, want) {
+		t.Errorf("parseNotes = %q, want %q", notes, want)
+	}
+
+	// Cutting the notes short must not panic, and keeps the notes
+	// before the cut.
+	for n := range len(b) {
+		notes := noteMap{}
+		err := parseNotes(notes, b[:n], binary.LittleEndian)
+		got := notes[elf.NT_PRSTATUS]
+		if len(got) > 2 || len(got) > 0 && !reflect.DeepEqual(got, want[elf.NT_PRSTATUS][:len(got)]) {
+			t.Errorf("parseNotes(%d bytes) = %q, want a prefix of %q", n, got, want[elf.NT_PRSTATUS])
+		}
+		if err == nil && n > 0 && len(got) == 0 {
+			t.Errorf("parseNotes(%d bytes) found no notes and no error", n)
+		}
+	}
+}
+
+// TestTruncatedCore checks that a core file cut short, as by
+// RLIMIT_CORE, still loads.
+func TestTruncatedCore(t *testing.T) {
+	b, err := os.ReadFile("testdata/core")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, n := range []int{len(b) / 2, len(b) / 8, 4096, 1024} {
+		f := filepath.Join(t.TempDir(), "core")
+		if err := os.WriteFile(f, b[:n], 0o644); err != nil {
+			t.Fatal(err)
+		}
+		// Short enough cores lose the notes that say where the
+		// executable is, so pass it explicitly.
+		p, err := Core(f, "testdata", "testdata/tmp/test")
+		if err != nil {
+			t.Errorf("Core(%d bytes): %v", n, err)
+			continue
+		}
+		truncated := false
+		for _, w := range p.Warnings() {
+			truncated = truncated || strings.Contains(w, "truncated")
+		}
+		if !truncated {
+			t.Errorf("Core(%d bytes): no warning about truncation in %q", n, p.Warnings())
+		}
+	}
+}
+
+// TestBuildIDMismatch checks that loading a core with an executable
+// other than the one that dumped it warns about it.
+func TestBuildIDMismatch(t *testing.T) {
+	for _, w := range loadExample(t, true).Warnings() {
+		if strings.Contains(w, "build ID") {
+			t.Errorf("unexpected warning with the right executable: %s", w)
+		}
+	}
+
+	b, err := os.ReadFile("testdata/tmp/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	e, err := elf.NewFile(bytes.NewReader(b))
+	if err != nil {
+		t.Fatal(err)
+	}
+	sec := e.Section(".note.go.buildid")
+	if sec == nil {
+		t.Fatal("no .note.go.buildid section in testdata/tmp/test")
+	}
+	// Change the last byte of the build ID, keeping it printable.
+	b[sec.Offset+sec.Size-1] ^= 1
+	exe := filepath.Join(t.TempDir(), "test")
+	if err := os.WriteFile(exe, b, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	p, err := Core("testdata/core", "testdata", exe)
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	for _, w := range p.Warnings() {
+		if strings.Contains(w, "Go build ID") {
+			return
+		}
+	}
+	t.Errorf("no warning about the Go build ID in %q", p.Warnings())
+}
+
+// TestFindGoLibrary checks that findGoLibrary picks the mapped file
+// holding the Go runtime, as for a Go c-shared library loaded by a C
+// program, and works out where it was loaded.
+func TestFindGoLibrary(t *testing.T) {
+	// A copy of the Go test binary without the runtime.buildVersion
+	// symbol stands for a C library.
+	b, err := os.ReadFile("testdata/tmp/test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b = bytes.ReplaceAll(b, []byte("runtime.buildVersion\x00"), []byte("runtime.buildVersioN\x00"))
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "libc.so"), b, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	const loadBase = 0x7f0000000000
+	mappings := []namedMapping{
+		{min: 0x1000, max: 0x2000, f: "/README", off: 0},                                   // not ELF
+		{min: 0x7e0000000000, max: 0x7e0000001000, f: "/libc.so", off: 0},                  // not Go
+		{min: loadBase + 0x451000, max: loadBase + 0x452000, f: "/tmp/test", off: 0x51000}, // not the start of the file
+		{min: loadBase + 0x400000, max: loadBase + 0x451000, f: "/tmp/test", off: 0},
+	}
+	f, e, base := findGoLibrary(mappings, "testdata"+string(os.PathListSeparator)+dir)
+	if f == nil {
+		t.Fatal("findGoLibrary found no Go library")
+	}
+	defer f.Close()
+	if want := filepath.Join("testdata", "tmp", "test"); f.Name() != want {
+		t.Errorf("findGoLibrary found %s, want %s", f.Name(), want)
+	}
+	if !hasSymbol(e, "runtime.buildVersion") {
+		t.Error("the library found has no runtime.buildVersion")
+	}
+	if base != loadBase {
+		t.Errorf("findGoLibrary's load base is %#x, want %#x", base, loadBase)
+	}
+
+	if f, _, _ := findGoLibrary(mappings[:2], "testdata"+string(os.PathListSeparator)+dir); f != nil {
+		f.Close()
+		t.Errorf("findGoLibrary found %s without a Go library", f.Name())
+	}
+
+	// Load the core with the copy as the executable. The Go code is then
+	// found in the mapped testdata/tmp/test, but the build IDs are still
+	// checked against the executable's own.
+	sec := e.Section(".note.go.buildid")
+	if sec == nil {
+		t.Fatal("no .note.go.buildid section in testdata/tmp/test")
+	}
+	for _, mismatch := range []bool{false, true} {
+		exe := filepath.Join(dir, "exe")
+		c := bytes.Clone(b)
+		if mismatch {
+			c[sec.Offset+sec.Size-1] ^= 1
+		}
+		if err := os.WriteFile(exe, c, 0o755); err != nil {
+			t.Fatal(err)
+		}
+		p, err := Core("testdata/core", "testdata", exe)
+		if err != nil {
+			t.Fatalf("can't load test core file: %s", err)
+		}
+		syms, err := p.Symbols()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, ok := syms["runtime.buildVersion"]; !ok {
+			t.Errorf("mismatch=%v: symbols not read from the Go library", mismatch)
+		}
+		warned := false
+		for _, w := range p.Warnings() {
+			warned = warned || strings.Contains(w, "Go build ID")
+		}
+		if warned != mismatch {
+			t.Errorf("mismatch=%v: build ID warning is %v; warnings: %q", mismatch, warned, p.Warnings())
+		}
+		p.Close()
+	}
+}
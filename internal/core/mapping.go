@@ -7,6 +7,7 @@ package core
 import (
 	"fmt"
 	"os"
+	"sort"
 )
 
 // A Mapping represents a contiguous subset of the inferior's address space.
@@ -24,8 +25,14 @@ type Mapping struct {
 	origF   *os.File
 	origOff int64
 
+	fromCore bool // f is the core file
+
 	// Contents of f at offset off. Length=max-min.
 	contents []byte
+
+	// The memory mapping of f that contents is a slice of, if any.
+	// See Process.Close.
+	mapped []byte
 }
 
 func (m Mapping) String() string {
@@ -40,6 +47,33 @@ func (m Mapping) String() string {
 	return fmt.Sprintf("0x%x-0x%x %s %8d %s+0x%x%s", m.min, m.max, m.perm, m.max-m.min, name, m.off, orig)
 }
 
+// mergeMappings sorts mappings by address and combines neighbors that
+// abut and have the same permissions and source. Neighbors with no source
+// at all (which will read as zero) are combined regardless of offset.
+func mergeMappings(mappings []*Mapping) []*Mapping {
+	if len(mappings) == 0 {
+		return mappings
+	}
+	sort.Slice(mappings, func(i, j int) bool {
+		return mappings[i].min < mappings[j].min
+	})
+	ms := mappings[1:]
+	mappings = mappings[:1]
+	for _, m := range ms {
+		k := mappings[len(mappings)-1]
+		if m.min == k.max &&
+			m.perm == k.perm &&
+			m.f == k.f &&
+			(m.f == nil || m.off == k.off+k.Size()) {
+			k.max = m.max
+			// TODO: also check origF?
+		} else {
+			mappings = append(mappings, m)
+		}
+	}
+	return mappings
+}
+
 // namedMapping is equivalent to Mapping, just using the filename rather than
 // opened file.
 type namedMapping struct {
@@ -98,6 +132,16 @@ func (m *Mapping) OrigSource() (string, int64) {
 	return m.origF.Name(), m.origOff
 }
 
+// DataSource reports where the mapping's contents were read from.
+// fromCore is true if they were read from the core file, so they are the
+// inferior's memory at the time it dumped core. Otherwise they were read
+// from a mapped file, or are all zero because neither the core nor a file
+// had them. stale is true in that case if the mapping is writable, as the
+// inferior may have changed the memory since.
+func (m *Mapping) DataSource() (fromCore, stale bool) {
+	return m.fromCore, !m.fromCore && m.perm&Write != 0
+}
+
 // A Perm represents the permissions allowed for a Mapping.
 type Perm uint8
 
@@ -1,6 +1,7 @@
 package core
 
 import (
+	"os"
 	"reflect"
 	"testing"
 )
@@ -138,3 +139,40 @@ func TestSplicedMemoryAdd(t *testing.T) {
 		})
 	}
 }
+
+func TestMergeMappings(t *testing.T) {
+	f := os.NewFile(0, "file")
+	in := []*Mapping{
+		{min: 4 * pageSize, max: 5 * pageSize, perm: Read},
+		{min: 0 * pageSize, max: 1 * pageSize, perm: Read, f: f, off: 0},
+		{min: 1 * pageSize, max: 2 * pageSize, perm: Read, f: f, off: int64(1 * pageSize)},
+		{min: 2 * pageSize, max: 3 * pageSize, perm: Read, f: f, off: int64(5 * pageSize)},
+		{min: 5 * pageSize, max: 6 * pageSize, perm: Read, off: 7},
+		{min: 6 * pageSize, max: 7 * pageSize, perm: Read | Write},
+	}
+	type region struct {
+		min, max Address
+		perm     Perm
+		off      int64
+	}
+	want := []region{
+		{min: 0 * pageSize, max: 2 * pageSize, perm: Read, off: 0},
+		{min: 2 * pageSize, max: 3 * pageSize, perm: Read, off: int64(5 * pageSize)},
+		{min: 4 * pageSize, max: 6 * pageSize, perm: Read, off: 0},
+		{min: 6 * pageSize, max: 7 * pageSize, perm: Read | Write, off: 0},
+	}
+	ms := mergeMappings(in)
+	var got []region
+	for _, m := range ms {
+		got = append(got, region{m.min, m.max, m.perm, m.off})
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("mappings = %+v,\nwant %+v", got, want)
+	}
+
+	p := &Process{memory: splicedMemory{mappings: ms}}
+	wantGaps := []AddressRange{{Min: 3 * pageSize, Max: 4 * pageSize}}
+	if gaps := p.Gaps(); !reflect.DeepEqual(gaps, wantGaps) {
+		t.Errorf("Gaps() = %+v, want %+v", gaps, wantGaps)
+	}
+}
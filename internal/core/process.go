@@ -21,10 +21,13 @@ import (
 	"debug/dwarf"
 	"debug/elf" // TODO: use golang.org/x/debug/elf instead?
 	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
+	"math"
 	"os"
 	"path/filepath"
-	"sort"
+	"strconv"
 	"strings"
 	"syscall"
 )
@@ -40,7 +43,7 @@ type Process struct {
 	meta metadata // basic metadata about the core
 
 	entryPoint Address
-	staticBase uint64    // Offset at which the executable was loaded in memory. 0 when binary is not-PIE.
+	staticBase uint64    // Offset at which the Go binary was loaded in memory. 0 when binary is not-PIE.
 	args       string    // first part of args retrieved from NT_PRPSINFO
 	threads    []*Thread // os threads (TODO: map from pid?)
 
@@ -122,6 +125,19 @@ func (p *Process) Mappings() []*Mapping {
 	return p.memory.mappings
 }
 
+// Gaps returns the unmapped ranges of p's address space that lie between
+// its mappings, in increasing address order.
+func (p *Process) Gaps() []AddressRange {
+	var gaps []AddressRange
+	ms := p.memory.mappings
+	for i := 1; i < len(ms); i++ {
+		if ms[i-1].max < ms[i].min {
+			gaps = append(gaps, AddressRange{Min: ms[i-1].max, Max: ms[i].min})
+		}
+	}
+	return gaps
+}
+
 // Readable reports whether the address a is readable.
 func (p *Process) Readable(a Address) bool {
 	return p.pageTable.findMapping(a) != nil
@@ -189,8 +205,10 @@ func (p *Process) Symbols() (map[string]Address, error) {
 	return p.syms, p.symErr
 }
 
-// StaticBase returns the offset at which the main executable was loaded in
-// memory. For example, it should be used when dereferencing DWARF locations.
+// StaticBase returns the offset at which the binary containing the Go code
+// was loaded in memory. That is the main executable, except for programs
+// built with -buildmode=c-shared, where it is the Go shared library.
+// For example, it should be used when dereferencing DWARF locations.
 func (p *Process) StaticBase() uint64 {
 	return p.staticBase
 }
@@ -199,10 +217,17 @@ var mapFile = func(fd int, offset int64, length int) (data []byte, err error) {
 	return nil, fmt.Errorf("file mapping is not implemented yet")
 }
 
+var unmapFile = func(data []byte) error {
+	return fmt.Errorf("file mapping is not implemented yet")
+}
+
 // Core takes the path to a core file and returns a Process that
 // represents the state of the inferior that generated the core file.
 //
 // base is the base directory from which files in the core can be found.
+// It may be a list of directories separated by os.PathListSeparator, in
+// which case each directory is tried in turn. The file each mapping was
+// read from is reported by Mapping.Source.
 //
 // exePath is the path of the main executable. If "", the path will be
 // determined from the core itself.
@@ -222,7 +247,7 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		return nil, fmt.Errorf("error reading metadata: %v", err)
 	}
 
-	notes, err := readCoreNotes(coreFile, coreElf)
+	notes, warnings, err := readCoreNotes(coreFile, coreElf)
 	if err != nil {
 		return nil, err
 	}
@@ -237,13 +262,13 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		var err error
 		exeFile, err = os.Open(exePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open executable file: %v", err)
+			return nil, fmt.Errorf("failed to open executable file: %w", err)
 		}
 	} else {
 		var err error
-		exeFile, err = os.Open(filepath.Join(base, origExePath))
+		exeFile, err = openMappedFile(base, origExePath)
 		if err != nil {
-			return nil, fmt.Errorf("failed to open executable file: %v", err)
+			return nil, fmt.Errorf("failed to open executable file: %w", err)
 		}
 	}
 	defer exeFile.Close()
@@ -253,9 +278,16 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		return nil, fmt.Errorf("failed to parse executable: %v", err)
 	}
 
-	staticBase := uint64(entryPoint) - exeElf.Entry // If not PIE, this is 0.
-	if exeElf.Entry > uint64(entryPoint) {
-		return nil, fmt.Errorf("malformed binary or core, core entry point (%d) - executable entry point (%d) is < 0", entryPoint, exeElf.Entry)
+	var staticBase uint64 // If not PIE, this is 0.
+	if entryPoint == 0 {
+		// The core is missing its NT_AUXV note, perhaps because it
+		// was truncated. We can only guess.
+		warnings = append(warnings, "No entry point in core. Assuming the executable was loaded at its link address.")
+	} else {
+		staticBase = uint64(entryPoint) - exeElf.Entry
+		if exeElf.Entry > uint64(entryPoint) {
+			return nil, fmt.Errorf("malformed binary or core, core entry point (%d) - executable entry point (%d) is < 0", entryPoint, exeElf.Entry)
+		}
 	}
 
 	// The base memory layout is defined by the binary itself. Additional
@@ -263,54 +295,52 @@ func Core(corePath, base, exePath string) (*Process, error) {
 	// ensure that dirty data/bss pages from the core take priority over
 	// the initial state from the binary.
 	mem := readExecMappings(exeFile, exeElf, staticBase)
-	addCoreMappings(&mem, coreFile, coreElf)
+	warnings = append(warnings, addCoreMappings(&mem, coreFile, coreElf)...)
 	// Add os.File references to mappings of files.
-	warnings := updateMappingFiles(&mem, fileMappings, base, exeFile, origExePath)
+	warnings = append(warnings, updateMappingFiles(&mem, fileMappings, base, exeFile, origExePath)...)
+	for _, m := range mem.mappings {
+		m.fromCore = m.f == coreFile
+	}
 
 	threads := readThreads(meta, notes)
 	args, err := readArgs(meta, notes)
 	if err != nil {
-		return nil, fmt.Errorf("error reading args: %v", err)
+		warnings = append(warnings, fmt.Sprintf("Can't read args: %v.", err))
 	}
 
+	// Symbols and DWARF come from the binary holding the Go code. That's
+	// usually the executable, but with -buildmode=c-shared the executable
+	// is a C program and the Go code lives in a shared library.
+	// staticBase is then the library's; exeBase stays the executable's.
+	goFile, goElf := exeFile, exeElf
+	exeBase := staticBase
 	syms, symErr := readSymbols(staticBase, exeElf)
+	if _, ok := syms["runtime.buildVersion"]; !ok {
+		if f, e, b := findGoLibrary(fileMappings, base); f != nil {
+			defer f.Close()
+			goFile, goElf, staticBase = f, e, b
+			syms, symErr = readSymbols(staticBase, goElf)
+		}
+	}
 	if symErr != nil {
-		symErr = fmt.Errorf("%v: from file %s", symErr, exeFile.Name())
+		symErr = fmt.Errorf("%v: from file %s", symErr, goFile.Name())
 	}
 
-	dwarf, dwarfErr := exeElf.DWARF()
+	dwarf, dwarfErr := goElf.DWARF()
 	if dwarfErr != nil {
-		dwarfErr = fmt.Errorf("error reading DWARF info from %s: %v", exeFile.Name(), dwarfErr)
+		dwarfErr = fmt.Errorf("error reading DWARF info from %s: %v", goFile.Name(), dwarfErr)
 	}
 	var dwarfLoc []byte
-	if locSection := exeElf.Section(".debug_loc"); locSection != nil {
+	if locSection := goElf.Section(".debug_loc"); locSection != nil {
 		var err error
 		dwarfLoc, err = locSection.Data()
 		if err != nil && dwarfErr == nil {
-			dwarfErr = fmt.Errorf("error reading DWARF location list section from %s: %v", exeFile.Name(), err)
+			dwarfErr = fmt.Errorf("error reading DWARF location list section from %s: %v", goFile.Name(), err)
 		}
 	}
 
 	// Sort then merge mappings, just to clean up a bit.
-	mappings := mem.mappings
-	sort.Slice(mappings, func(i, j int) bool {
-		return mappings[i].min < mappings[j].min
-	})
-	ms := mappings[1:]
-	mappings = mappings[:1]
-	for _, m := range ms {
-		k := mappings[len(mappings)-1]
-		if m.min == k.max &&
-			m.perm == k.perm &&
-			m.f == k.f &&
-			m.off == k.off+k.Size() {
-			k.max = m.max
-			// TODO: also check origF?
-		} else {
-			mappings = append(mappings, m)
-		}
-	}
-	mem.mappings = mappings
+	mem.mappings = mergeMappings(mem.mappings)
 
 	// Memory map all the mappings.
 	hostPageSize := int64(syscall.Getpagesize())
@@ -351,6 +381,8 @@ func Core(corePath, base, exePath string) (*Process, error) {
 			return nil, fmt.Errorf("can't memory map %s at %x: %s\n", m.f.Name(), minOff, err)
 		}
 
+		m.mapped = data
+
 		// Trim any data we mapped but don't need.
 		data = data[m.off-minOff:]
 		data = data[:size]
@@ -358,6 +390,8 @@ func Core(corePath, base, exePath string) (*Process, error) {
 		m.contents = data
 	}
 
+	warnings = append(warnings, checkBuildIDs(mem.mappings, exeFile, exeElf, exeBase)...)
+
 	// Build page table for mapping lookup.
 	var pageTable pageTable4
 	for _, m := range mem.mappings {
@@ -386,6 +420,34 @@ func Core(corePath, base, exePath string) (*Process, error) {
 	return p, nil
 }
 
+// Close releases the resources p holds: the memory mappings of the core
+// and the files it references, and those files. Neither p nor anything
+// loaded from it, such as a gocore.Process, may be used after Close.
+func (p *Process) Close() error {
+	var firstErr error
+	closed := map[*os.File]bool{}
+	for _, m := range p.memory.mappings {
+		if m.mapped != nil {
+			if err := unmapFile(m.mapped); err != nil && firstErr == nil {
+				firstErr = err
+			}
+		}
+		m.mapped, m.contents = nil, nil
+		for _, f := range []*os.File{m.f, m.origF} {
+			if f == nil || closed[f] {
+				continue
+			}
+			closed[f] = true
+			// Core closes the core file and the executable once
+			// they are mapped, but keeps other files open.
+			if err := f.Close(); err != nil && !errors.Is(err, os.ErrClosed) && firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
+
 // readExecMappings returns the memory mappings defined by the executable
 // itself. staticBase should be the offset at which the executable was loaded in
 // memory.
@@ -394,26 +456,117 @@ func readExecMappings(exeFile *os.File, exeElf *elf.File, staticBase uint64) spl
 	var mem splicedMemory
 	for _, prog := range exeElf.Progs {
 		if prog.Type == elf.PT_LOAD {
-			addProgMappings(&mem, prog, exeFile, staticBase)
+			addProgMappings(&mem, &prog.ProgHeader, exeFile, staticBase)
 		}
 	}
 	return mem
 }
 
+// checkBuildIDs compares the build IDs recorded in the executable, in
+// its .note.go.buildid and .note.gnu.build-id sections, with the copies
+// the core holds of them. By default the kernel dumps the first page of
+// each mapped ELF file, which holds them. It returns a warning for each
+// that differs: the executable isn't the one that dumped core, so
+// symbols, types and anything read from its mappings are wrong.
+// mappings must be populated.
+func checkBuildIDs(mappings []*Mapping, exeFile *os.File, exeElf *elf.File, staticBase uint64) []string {
+	var warnings []string
+	for _, kind := range []struct{ section, name string }{
+		{".note.go.buildid", "Go"},
+		{".note.gnu.build-id", "GNU"},
+	} {
+		sec := exeElf.Section(kind.section)
+		if sec == nil || sec.Type != elf.SHT_NOTE || sec.Addr == 0 {
+			continue
+		}
+		exeNote, err := sec.Data()
+		if err != nil {
+			continue
+		}
+		min := Address(sec.Addr).Add(int64(staticBase))
+		max := min.Add(int64(len(exeNote)))
+		for _, m := range mappings {
+			if !m.fromCore || min < m.min || max > m.max {
+				continue
+			}
+			coreNote := m.contents[min.Sub(m.min):max.Sub(m.min)]
+			exeID := noteDesc(exeNote, exeElf.ByteOrder)
+			coreID := noteDesc(coreNote, exeElf.ByteOrder)
+			if !bytes.Equal(exeID, coreID) {
+				warnings = append(warnings, fmt.Sprintf(
+					"Executable %s does not match the core: its %s build ID is %s, but the core's is %s. Symbols, types and stacks will be wrong.",
+					exeFile.Name(), kind.name, formatBuildID(kind.name, exeID), formatBuildID(kind.name, coreID)))
+			}
+			break
+		}
+	}
+	return warnings
+}
+
+// noteDesc returns the desc of the ELF note at the start of b, or nil
+// if b doesn't hold a whole note.
+func noteDesc(b []byte, order binary.ByteOrder) []byte {
+	if len(b) < 12 {
+		return nil
+	}
+	namesz := uint64(order.Uint32(b))
+	descsz := uint64(order.Uint32(b[4:]))
+	b = b[12:]
+	nameEnd := (namesz + 3) / 4 * 4
+	if nameEnd > uint64(len(b)) || descsz > uint64(len(b))-nameEnd {
+		return nil
+	}
+	return b[nameEnd : nameEnd+descsz]
+}
+
+// formatBuildID formats a build ID from a note named name: Go's are
+// text, while GNU ones are binary and shown in hex.
+func formatBuildID(name string, id []byte) string {
+	if id == nil {
+		return "unreadable"
+	}
+	if name == "Go" {
+		return strconv.Quote(string(id))
+	}
+	return fmt.Sprintf("%x", id)
+}
+
 // addCoreMappings adds memory mappings from the core file to mem.
-func addCoreMappings(mem *splicedMemory, coreFile *os.File, coreElf *elf.File) {
+// If the core file was truncated, for example because it hit
+// RLIMIT_CORE, the data past its end is treated as missing. The returned
+// warnings say so.
+func addCoreMappings(mem *splicedMemory, coreFile *os.File, coreElf *elf.File) []string {
+	var warnings []string
+	size := int64(math.MaxInt64)
+	if fi, err := coreFile.Stat(); err == nil {
+		size = fi.Size()
+	}
+	loads := 0
 	for _, prog := range coreElf.Progs {
-		if prog.Type == elf.PT_LOAD {
-			addProgMappings(mem, prog, coreFile, 0)
+		if prog.Type != elf.PT_LOAD {
+			continue
 		}
+		loads++
+		h := prog.ProgHeader
+		if end := int64(h.Off + h.Filesz); end > size {
+			n := max(size-int64(h.Off), 0)
+			warnings = append(warnings,
+				fmt.Sprintf("Core file truncated: only %d of %d bytes of data for addresses [%x %x].", n, h.Filesz, h.Vaddr, h.Vaddr+h.Memsz))
+			h.Filesz = uint64(n)
+		}
+		addProgMappings(mem, &h, coreFile, 0)
+	}
+	if loads == 0 {
+		warnings = append(warnings, "Core file has no PT_LOAD segments. Memory is only what the executable and mapped files provide.")
 	}
+	return warnings
 }
 
 // addProgMappings adds memory mappings for prog (from file f) to mem.
 // staticBase is added to the p_vaddr [1].
 //
 // [1]: https://man7.org/linux/man-pages/man5/elf.5.html
-func addProgMappings(mem *splicedMemory, prog *elf.Prog, f *os.File, staticBase uint64) {
+func addProgMappings(mem *splicedMemory, prog *elf.ProgHeader, f *os.File, staticBase uint64) {
 	min := Address(prog.Vaddr)
 	min = min.Add(int64(staticBase))
 	max := min.Add(int64(prog.Memsz))
@@ -451,8 +604,11 @@ func addProgMappings(mem *splicedMemory, prog *elf.Prog, f *os.File, staticBase
 type noteMap map[elf.NType][][]byte
 
 // readCoreNotes returns contents of all CORE ELF notes from the core file.
-func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, error) {
+// If the notes are cut short, as in a truncated core file, it returns
+// the complete ones, and warnings about the rest.
+func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, []string, error) {
 	notes := make(noteMap)
+	var warnings []string
 
 	for _, prog := range coreElf.Progs {
 		if prog.Type != elf.PT_NOTE {
@@ -460,31 +616,52 @@ func readCoreNotes(coreFile *os.File, coreElf *elf.File) (noteMap, error) {
 		}
 
 		b := make([]byte, prog.Filesz)
-		_, err := coreFile.ReadAt(b, int64(prog.Off))
-		if err != nil {
-			return nil, fmt.Errorf("error reading notes at offset %d: %v", prog.Off, err)
-		}
-		for len(b) > 0 {
-			namesz := coreElf.ByteOrder.Uint32(b)
-			b = b[4:]
-			descsz := coreElf.ByteOrder.Uint32(b)
-			b = b[4:]
-			typ := elf.NType(coreElf.ByteOrder.Uint32(b))
-			b = b[4:]
-			name := string(b[:namesz-1])
-			b = b[(namesz+3)/4*4:]
-			desc := b[:descsz]
-			b = b[(descsz+3)/4*4:]
-
-			if name != "CORE" {
-				continue
-			}
-
-			notes[typ] = append(notes[typ], desc)
+		n, err := coreFile.ReadAt(b, int64(prog.Off))
+		if err != nil && err != io.EOF {
+			return nil, nil, fmt.Errorf("error reading notes at offset %d: %v", prog.Off, err)
+		}
+		if n < len(b) {
+			warnings = append(warnings, fmt.Sprintf("Core file truncated: only %d of %d bytes of notes at offset %d.", n, len(b), prog.Off))
+			b = b[:n]
+		}
+		if err := parseNotes(notes, b, coreElf.ByteOrder); err != nil {
+			warnings = append(warnings, fmt.Sprintf("Bad notes at offset %d: %v.", prog.Off, err))
 		}
 	}
 
-	return notes, nil
+	return notes, warnings, nil
+}
+
+// parseNotes adds the CORE notes in b, the contents of a PT_NOTE
+// segment, to notes. If a note runs off the end of b, it adds the notes
+// before it, and returns an error.
+func parseNotes(notes noteMap, b []byte, order binary.ByteOrder) error {
+	for len(b) > 0 {
+		if len(b) < 12 {
+			return fmt.Errorf("%d bytes left, too short for a note header", len(b))
+		}
+		namesz := uint64(order.Uint32(b))
+		descsz := uint64(order.Uint32(b[4:]))
+		typ := elf.NType(order.Uint32(b[8:]))
+		b = b[12:]
+		// The name and desc are each padded to a multiple of 4 bytes.
+		// Allow the padding after the last desc to be missing.
+		nameEnd := (namesz + 3) / 4 * 4
+		if nameEnd > uint64(len(b)) || descsz > uint64(len(b))-nameEnd {
+			return fmt.Errorf("note of type %d with %d bytes of name and %d of desc, but only %d bytes left", typ, namesz, descsz, len(b))
+		}
+		name := strings.TrimSuffix(string(b[:namesz]), "\x00")
+		b = b[nameEnd:]
+		desc := b[:descsz]
+		b = b[min((descsz+3)/4*4, uint64(len(b))):]
+
+		if name != "CORE" {
+			continue
+		}
+
+		notes[typ] = append(notes[typ], desc)
+	}
+	return nil
 }
 
 func readEntryPoint(meta metadata, notes noteMap) Address {
@@ -498,15 +675,10 @@ func readEntryPoint(meta metadata, notes noteMap) Address {
 	// We don't expect multiple NT_AUXV notes. Just use the first.
 	desc := notes[_NT_AUXV][0]
 
-	buf := bytes.NewBuffer(desc)
-	for {
-		var tag, val uint64
-		if err := binary.Read(buf, meta.byteOrder, &tag); err != nil {
-			panic(err)
-		}
-		if err := binary.Read(buf, meta.byteOrder, &val); err != nil {
-			panic(err)
-		}
+	for len(desc) >= 16 {
+		tag := meta.byteOrder.Uint64(desc)
+		val := meta.byteOrder.Uint64(desc[8:])
+		desc = desc[16:]
 		if tag == _AT_ENTRY_AMD64 {
 			return Address(val)
 		}
@@ -522,22 +694,33 @@ func readFileMappings(meta metadata, notes noteMap) []namedMapping {
 	// We don't expect multiple NT_FILE notes. Just use the first.
 	desc := notes[_NT_FILE][0]
 
-	// TODO: 4 instead of 8 for 32-bit machines?
-	count := meta.byteOrder.Uint64(desc)
-	desc = desc[8:]
-	pagesize := meta.byteOrder.Uint64(desc)
-	desc = desc[8:]
-	filenames := string(desc[3*8*count:])
-	desc = desc[:3*8*count]
+	// The header and the entries are made of words of the
+	// inferior's pointer size.
+	word := func() uint64 {
+		var v uint64
+		if meta.ptrSize == 4 {
+			v = uint64(meta.byteOrder.Uint32(desc))
+		} else {
+			v = meta.byteOrder.Uint64(desc)
+		}
+		desc = desc[meta.ptrSize:]
+		return v
+	}
+	if int64(len(desc)) < 2*meta.ptrSize {
+		return nil
+	}
+	count := word()
+	pagesize := word()
+	// Don't trust count in a truncated note.
+	count = min(count, uint64(int64(len(desc))/(3*meta.ptrSize)))
+	filenames := string(desc[3*uint64(meta.ptrSize)*count:])
+	desc = desc[:3*uint64(meta.ptrSize)*count]
 
 	var mappings []namedMapping
 	for i := uint64(0); i < count; i++ {
-		min := Address(meta.byteOrder.Uint64(desc))
-		desc = desc[8:]
-		max := Address(meta.byteOrder.Uint64(desc))
-		desc = desc[8:]
-		off := int64(meta.byteOrder.Uint64(desc) * pagesize)
-		desc = desc[8:]
+		min := Address(word())
+		max := Address(word())
+		off := int64(word() * pagesize)
 
 		var name string
 		j := strings.IndexByte(filenames, 0)
@@ -573,10 +756,77 @@ func findExe(mappings []namedMapping, entryPoint Address) string {
 	return ""
 }
 
+// findGoLibrary looks through the files mapped into the inferior for a
+// shared library containing the Go runtime, as built by -buildmode=c-shared.
+// It returns the open library, its ELF headers, and the offset at which it
+// was loaded, or nils if no such library can be found.
+func findGoLibrary(mappings []namedMapping, base string) (*os.File, *elf.File, uint64) {
+	seen := map[string]bool{}
+	for _, m := range mappings {
+		if m.off != 0 || seen[m.f] {
+			continue
+		}
+		seen[m.f] = true
+		f, err := openMappedFile(base, m.f)
+		if err != nil {
+			continue
+		}
+		e, err := elf.NewFile(f)
+		if err == nil && hasSymbol(e, "runtime.buildVersion") {
+			for _, prog := range e.Progs {
+				if prog.Type == elf.PT_LOAD {
+					// m maps the start of the file, which is where the
+					// first loadable segment begins.
+					return f, e, uint64(m.min) - (prog.Vaddr - prog.Off)
+				}
+			}
+		}
+		f.Close()
+	}
+	return nil, nil, 0
+}
+
+// hasSymbol reports whether e's symbol table contains name.
+func hasSymbol(e *elf.File, name string) bool {
+	syms, err := e.Symbols()
+	if err != nil {
+		return false
+	}
+	for _, s := range syms {
+		if s.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// openMappedFile opens name, a path recorded in the core, under the
+// directories in base, a list separated by os.PathListSeparator.
+// It returns the first file found, or the error from the first
+// directory if none is.
+func openMappedFile(base, name string) (*os.File, error) {
+	dirs := filepath.SplitList(base)
+	if len(dirs) == 0 {
+		dirs = []string{""}
+	}
+	var firstErr error
+	for _, dir := range dirs {
+		f, err := os.Open(filepath.Join(dir, name))
+		if err == nil {
+			return f, nil
+		}
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	return nil, firstErr
+}
+
 // updateMappingFiles adds os.File references to mappings in mem of files in
 // fileMappings.
 //
-// base is the base directory from which files in fileMappings can be found.
+// base is the list of directories from which files in fileMappings can be
+// found. See openMappedFile.
 //
 // exeFile is the reference to the executable, which is named origExePath in
 // fileMappings.
@@ -594,7 +844,7 @@ func updateMappingFiles(mem *splicedMemory, fileMappings []namedMapping, base st
 			return f.f, f.err
 		}
 
-		f, err := os.Open(filepath.Join(base, name))
+		f, err := openMappedFile(base, name)
 		file := &file{f: f, err: err}
 		files[name] = file
 		return f, err
@@ -669,7 +919,6 @@ func readThreads(meta metadata, notes noteMap) []*Thread {
 
 	for _, desc := range notes[elf.NT_PRSTATUS] {
 		t := &Thread{}
-		threads = append(threads, t)
 		// Linux
 		//   sys/procfs.h:
 		//     struct elf_prstatus {
@@ -686,6 +935,9 @@ func readThreads(meta metadata, notes noteMap) []*Thread {
 		default:
 			// TODO: return error here?
 		case "amd64":
+			if len(desc) < 112+216 {
+				continue // truncated
+			}
 			// 32 = offsetof(prstatus_t, pr_pid), 4 = sizeof(pid_t)
 			t.pid = uint64(meta.byteOrder.Uint32(desc[32 : 32+4]))
 			// 112 = offsetof(prstatus_t, pr_reg), 216 = sizeof(elf_gregset_t)
@@ -732,7 +984,25 @@ func readThreads(meta metadata, notes noteMap) []*Thread {
 			// the thread described by the previous NT_PRSTATUS
 			// rather than directly denoting which thread they
 			// belong to.
+		case "arm":
+			if len(desc) < 72+72 {
+				continue // truncated
+			}
+			// 24 = offsetof(prstatus_t, pr_pid), 4 = sizeof(pid_t)
+			t.pid = uint64(meta.byteOrder.Uint32(desc[24 : 24+4]))
+			// 72 = offsetof(prstatus_t, pr_reg), 72 = sizeof(elf_gregset_t)
+			reg := desc[72 : 72+72]
+			for i := 0; i < 16; i++ {
+				value := uint64(meta.byteOrder.Uint32(reg[4*i:]))
+				t.regs = append(t.regs, Register{Name: fmt.Sprintf("r%d", i), Value: value})
+			}
+			t.sp = Address(t.regs[13].Value)
+			t.pc = Address(t.regs[15].Value)
+			t.regs = append(t.regs,
+				Register{Name: "cpsr", Value: uint64(meta.byteOrder.Uint32(reg[64:]))},
+				Register{Name: "orig_r0", Value: uint64(meta.byteOrder.Uint32(reg[68:]))})
 		}
+		threads = append(threads, t)
 	}
 
 	return threads
@@ -753,7 +1023,7 @@ func readSymbols(staticBase uint64, exeElf *elf.File) (map[string]Address, error
 
 	syms, err := exeElf.Symbols()
 	if err != nil {
-		return allSyms, fmt.Errorf("can't read symbols from main executable: %v", err)
+		return allSyms, fmt.Errorf("can't read symbols: %v", err)
 	}
 
 	for _, s := range syms {
@@ -16,4 +16,5 @@ func init() {
 	mapFile = func(fd int, offset int64, length int) (data []byte, err error) {
 		return unix.Mmap(fd, offset, length, syscall.PROT_READ, syscall.MAP_SHARED)
 	}
+	unmapFile = unix.Munmap
 }
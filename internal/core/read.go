@@ -5,8 +5,10 @@
 package core
 
 import (
+	"bytes"
 	"encoding/binary"
 	"fmt"
+	"io"
 )
 
 // All the Read* functions below will panic if something goes wrong.
@@ -134,12 +136,60 @@ func (p *Process) ReadPtr(a Address) Address {
 }
 
 // ReadCString reads a null-terminated string starting at address a.
-func (p *Process) ReadCString(a Address) string {
-	for n := int64(0); ; n++ {
-		if p.ReadUint8(a.Add(n)) == 0 {
-			b := make([]byte, n)
-			p.ReadAt(b, a)
-			return string(b)
+// It reads at most max bytes. Unlike the other Read* functions, it does
+// not panic if it runs off the end of readable memory; it just returns
+// what it found up to that point.
+func (p *Process) ReadCString(a Address, max int64) string {
+	var b []byte
+	for int64(len(b)) < max {
+		m := p.pageTable.findMapping(a)
+		if m == nil {
+			break
+		}
+		data := m.contents[a.Sub(m.min):]
+		if n := max - int64(len(b)); int64(len(data)) > n {
+			data = data[:n]
+		}
+		if i := bytes.IndexByte(data, 0); i >= 0 {
+			return string(append(b, data[:i]...))
+		}
+		// Continue into the next mapping, if any.
+		b = append(b, data...)
+		a = a.Add(int64(len(data)))
+	}
+	return string(b)
+}
+
+// ReaderAt returns an io.ReaderAt view of the inferior's address space,
+// whose offsets are addresses, for use with standard library parsers.
+// Unlike the Read* functions, its ReadAt doesn't panic. A read that
+// reaches unmapped memory returns the bytes before it and an error,
+// which is io.EOF if there is no mapped memory at or above it.
+func (p *Process) ReaderAt() io.ReaderAt {
+	return memReader{p}
+}
+
+type memReader struct {
+	p *Process
+}
+
+func (r memReader) ReadAt(b []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, fmt.Errorf("negative address %x", off)
+	}
+	a := Address(off)
+	n := 0
+	for n < len(b) {
+		m := r.p.pageTable.findMapping(a)
+		if m == nil {
+			if ms := r.p.Mappings(); len(ms) == 0 || a >= ms[len(ms)-1].max {
+				return n, io.EOF
+			}
+			return n, fmt.Errorf("address %x is not mapped in the core file", a)
 		}
+		k := copy(b[n:], m.contents[a.Sub(m.min):])
+		n += k
+		a = a.Add(int64(k))
 	}
+	return n, nil
 }
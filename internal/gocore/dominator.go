@@ -383,6 +383,52 @@ func (d *dominators) calcSize(p *Process) {
 	}
 }
 
+// RetainedByType returns, for each type, the number of bytes retained by
+// objects of that type: the sum of the sizes of everything they dominate,
+// including themselves. An object dominated by another object of the same
+// type is already accounted for by that object, so it is not counted again.
+// Objects of unknown type are not included.
+func (p *Process) RetainedByType() map[*Type]int64 {
+	d := p.calculateDominators()
+	retained := map[*Type]int64{}
+	active := map[*Type]int{} // number of objects of each type on the current dominator tree path
+	type workItem struct {
+		v    vName
+		mode dfsMode
+		t    *Type // type of v, if v is an object of known type
+	}
+	work := []workItem{{v: pseudoRoot, mode: down}}
+
+	for len(work) > 0 {
+		item := &work[len(work)-1]
+		if item.mode == up {
+			if item.t != nil {
+				active[item.t]--
+			}
+			work = work[:len(work)-1]
+			continue
+		}
+		item.mode = up
+		if _, obj := d.findVertexByName(item.v); obj != 0 {
+			if t, _ := p.Type(obj); t != nil {
+				if active[t] == 0 {
+					retained[t] += d.size[item.v]
+				}
+				active[t]++
+				item.t = t
+			}
+		}
+		for _, w := range d.redge[d.ridx[item.v]:d.ridx[item.v+1]] {
+			if w == 0 {
+				// bogus self-edge. Ignore.
+				continue
+			}
+			work = append(work, workItem{v: w, mode: down})
+		}
+	}
+	return retained
+}
+
 func (d *ltDom) dot(w io.Writer) {
 	fmt.Fprintf(w, "digraph %s {\nrankdir=\"LR\"\n", "dominators")
 	for number, name := range d.vertices {
@@ -393,7 +439,7 @@ func (d *ltDom) dot(w io.Writer) {
 		case name == 0:
 			label = "pseudo-root"
 		case root != nil:
-			typeName := root.Type.Name
+			typeName := d.p.TypeName(root.Type)
 			if len(typeName) > 30 {
 				typeName = typeName[:30]
 			}
@@ -402,7 +448,7 @@ func (d *ltDom) dot(w io.Writer) {
 			typ, _ := d.p.Type(obj)
 			var typeName string
 			if typ != nil {
-				typeName = typ.Name
+				typeName = d.p.TypeName(typ)
 				if len(typeName) > 30 {
 					typeName = typeName[:30]
 				}
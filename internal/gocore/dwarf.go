@@ -9,6 +9,7 @@ import (
 	"errors"
 	"fmt"
 	"reflect"
+	"slices"
 	"strings"
 
 	"golang.org/x/debug/internal/core"
@@ -23,22 +24,18 @@ const (
 	AttrGoRuntimeType dwarf.Attr = 0x2904
 )
 
-func readDWARFTypes(p *core.Process) (map[dwarf.Type]*Type, map[core.Address]*Type, error) {
-	d, err := p.DWARF()
-	if err != nil {
-		return nil, nil, fmt.Errorf("failed to read DWARF: %v", err)
-	}
+// readDWARFTypes makes a Type for each Go type in d, indexed both by
+// DWARF type and by runtime type address, relative to typBase. If strict
+// is set, it also returns a warning for each runtime type address
+// described by more than one disagreeing DWARF type.
+func readDWARFTypes(p *core.Process, d *dwarf.Data, typBase core.Address, strict bool) (map[dwarf.Type]*Type, map[core.Address]*Type, []string, error) {
 	dwarfMap := make(map[dwarf.Type]*Type)
 	addrMap := make(map[core.Address]*Type)
-	syms, _ := p.Symbols() // It's OK to ignore the error. If we don't have symbols, that's OK; it's a soft error.
-	// It's OK if typBase is 0 and not present. We won't be able to type the heap, probably,
-	// but it may still be useful (though painful) to someone to try and debug the core, so don't
-	// error out here.
-	typBase := syms["runtime.types"]
 
 	// Make one of our own Types for each dwarf type.
 	r := d.Reader()
 	var types []*Type
+	dupMap := make(map[core.Address][]*Type) // types displaced from addrMap, if strict
 	for e, err := r.Next(); e != nil && err == nil; e, err = r.Next() {
 		if isNonGoCU(e) {
 			r.SkipChildren()
@@ -51,6 +48,12 @@ func readDWARFTypes(p *core.Process) (map[dwarf.Type]*Type, map[core.Address]*Ty
 				continue
 			}
 			t := &Type{Name: gocoreName(dt), Size: dwarfSize(dt, p.PtrSize())}
+			if name, ok := e.Val(dwarf.AttrName).(string); ok && e.Tag == dwarf.TagSubroutineType {
+				// debug/dwarf ignores the names of function types, and
+				// reads Go's results as parameters: func() int would be
+				// "func(int) void".
+				t.Name = name
+			}
 			if goKind, ok := e.Val(AttrGoKind).(int64); ok {
 				t.goKind = reflect.Kind(goKind)
 			}
@@ -62,7 +65,11 @@ func readDWARFTypes(p *core.Process) (map[dwarf.Type]*Type, map[core.Address]*Ty
 					// N.B. AttrGoRuntimeType is not defined for typedefs, so addrMap will
 					// always refer to the base type.
 					t.goAddr = typBase.Add(int64(offset))
-					addrMap[typBase.Add(int64(offset))] = t
+					// Types without a runtime type descriptor all report offset 0.
+					if old := addrMap[t.goAddr]; old != nil && strict && offset != 0 {
+						dupMap[t.goAddr] = append(dupMap[t.goAddr], old)
+					}
+					addrMap[t.goAddr] = t
 				}
 			}
 			dwarfMap[dt] = t
@@ -100,7 +107,7 @@ func readDWARFTypes(p *core.Process) (map[dwarf.Type]*Type, map[core.Address]*Ty
 							Elem:  dwarfMap[arr.Type],
 						}
 					} else {
-						return nil, nil, fmt.Errorf(
+						return nil, nil, nil, fmt.Errorf(
 							"found a nil ftype for field %s.%s, type %s (%s) on ",
 							x.StructName, f.Name, f.Type, reflect.TypeOf(f.Type))
 					}
@@ -122,7 +129,7 @@ func readDWARFTypes(p *core.Process) (map[dwarf.Type]*Type, map[core.Address]*Ty
 		case *dwarf.TypedefType:
 			// handle these types in the loop below
 		default:
-			return nil, nil, fmt.Errorf("unknown type %s %T", dt, dt)
+			return nil, nil, nil, fmt.Errorf("unknown type %s %T", dt, dt)
 		}
 	}
 
@@ -181,7 +188,49 @@ func readDWARFTypes(p *core.Process) (map[dwarf.Type]*Type, map[core.Address]*Ty
 			t.Fields = nil
 		}
 	}
-	return dwarfMap, addrMap, nil
+
+	// Link defined types whose underlying type is a basic type, like
+	// time.Duration, to that predeclared type, making it if the DWARF
+	// doesn't describe it. DWARF doesn't record the underlying type of
+	// other defined types.
+	predeclared := make(map[string]*Type)
+	for _, t := range types {
+		if isBasic(t.goKind) && t.Name == t.goKind.String() {
+			predeclared[t.Name] = t
+		}
+	}
+	for _, t := range types {
+		if !isBasic(t.goKind) || t.Name == t.goKind.String() {
+			continue
+		}
+		name := t.goKind.String()
+		u := predeclared[name]
+		if u == nil {
+			u = &Type{Name: name, Size: t.Size, Kind: t.Kind, goKind: t.goKind, Elem: t.Elem}
+			predeclared[name] = u
+		}
+		t.underlying = u
+	}
+
+	// Report runtime types with conflicting DWARF descriptions.
+	// Compare only once all the types are filled in.
+	var warnings []string
+	for a, dups := range dupMap {
+		t := addrMap[a]
+		for _, d := range dups {
+			if !sameType(d, t) {
+				warnings = append(warnings, fmt.Sprintf("conflicting DWARF types for runtime type %x: %s", a, typeConflict(d, t)))
+			}
+		}
+	}
+	slices.Sort(warnings)
+	return dwarfMap, addrMap, warnings, nil
+}
+
+// isBasic reports whether k is the kind of a predeclared boolean,
+// numeric, or string type.
+func isBasic(k reflect.Kind) bool {
+	return reflect.Bool <= k && k <= reflect.Complex128 || k == reflect.String
 }
 
 func isNonGoCU(e *dwarf.Entry) bool {
@@ -273,11 +322,7 @@ func (c constsMap) find(s string) (int64, bool) {
 	return v, ok
 }
 
-func readDWARFConstants(p *core.Process) (constsMap, error) {
-	d, err := p.DWARF()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read DWARF: %v", err)
-	}
+func readDWARFConstants(d *dwarf.Data) (constsMap, error) {
 	consts := map[string]int64{}
 
 	r := d.Reader()
@@ -299,11 +344,11 @@ func readDWARFConstants(p *core.Process) (constsMap, error) {
 	return consts, nil
 }
 
-func readDWARFGlobals(p *core.Process, nRoots *int, dwarfTypeMap map[dwarf.Type]*Type) ([]*Root, error) {
-	d, err := p.DWARF()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read DWARF: %v", err)
-	}
+// readDWARFGlobals returns a root for each writeable global in d.
+// If syms is non-nil, d describes another binary built by the same
+// toolchain, so only the runtime's globals are read, and they are
+// located by symbol instead of by their DWARF location.
+func readDWARFGlobals(p *core.Process, d *dwarf.Data, syms map[string]core.Address, nRoots *int, dwarfTypeMap map[dwarf.Type]*Type) ([]*Root, error) {
 	var roots []*Root
 	r := d.Reader()
 	for e, err := r.Next(); e != nil && err == nil; e, err = r.Next() {
@@ -334,6 +379,17 @@ func readDWARFGlobals(p *core.Process, nRoots *int, dwarfTypeMap map[dwarf.Type]
 			a = core.Address(p.ByteOrder().Uint32(loc[1:]))
 		}
 		a = a.Add(int64(p.StaticBase()))
+		nf := e.AttrField(dwarf.AttrName)
+		if nf == nil {
+			continue
+		}
+		name := nf.Val.(string)
+		if syms != nil {
+			var ok bool
+			if a, ok = syms[name]; !ok || !strings.HasPrefix(name, "runtime.") {
+				continue
+			}
+		}
 		if !p.Writeable(a) {
 			// Read-only globals can't have heap pointers.
 			// TODO: keep roots around anyway?
@@ -350,12 +406,8 @@ func readDWARFGlobals(p *core.Process, nRoots *int, dwarfTypeMap map[dwarf.Type]
 		if _, ok := dt.(*dwarf.UnspecifiedType); ok {
 			continue // Ignore markers like data/edata.
 		}
-		nf := e.AttrField(dwarf.AttrName)
-		if nf == nil {
-			continue
-		}
 		typ := dwarfTypeMap[dt]
-		roots = append(roots, makeMemRoot(nRoots, nf.Val.(string), typ, nil, a))
+		roots = append(roots, makeMemRoot(nRoots, name, typ, nil, a))
 	}
 	return roots, nil
 }
@@ -376,11 +428,7 @@ type dwarfVar struct {
 	typ           *Type
 }
 
-func readDWARFVars(p *core.Process, fns *funcTab, dwarfTypeMap map[dwarf.Type]*Type) (map[*Func][]dwarfVar, error) {
-	d, err := p.DWARF()
-	if err != nil {
-		return nil, fmt.Errorf("failed to read DWARF: %v", err)
-	}
+func readDWARFVars(p *core.Process, d *dwarf.Data, fns *funcTab, dwarfTypeMap map[dwarf.Type]*Type) (map[*Func][]dwarfVar, error) {
 	dLocSec, err := p.DWARFLoc()
 	if err != nil {
 		return nil, fmt.Errorf("failed to read DWARF: %v", err)
@@ -0,0 +1,90 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// Records written by ExportGraph. Each node has an ID: "r<n>" for roots
+// and the hex address ("0x...") for objects. Addresses are written as
+// strings so that readers without 64-bit integers don't lose precision.
+type (
+	exportRoot struct {
+		Kind string `json:"kind"` // "root"
+		ID   string `json:"id"`
+		Name string `json:"name"`
+		Type string `json:"type"`
+	}
+	exportObject struct {
+		Kind   string `json:"kind"` // "object"
+		ID     string `json:"id"`
+		Type   string `json:"type,omitempty"` // empty if unknown
+		Repeat int64  `json:"repeat,omitempty"`
+		Size   int64  `json:"size"`
+	}
+	exportEdge struct {
+		Kind  string `json:"kind"` // "edge"
+		From  string `json:"from"`
+		Off   int64  `json:"off"` // offset of the pointer in From
+		To    string `json:"to"`
+		ToOff int64  `json:"toOff"` // offset in To that the pointer points at
+	}
+)
+
+// ExportGraph writes the object graph of p to w as a stream of JSON
+// objects, one per line. All roots come first, each followed by its
+// outgoing edges. Then come all objects, each followed by its outgoing
+// edges. Every record has a "kind" field, which is "root", "object" or
+// "edge".
+//
+// Unlike a single JSON document, the output can be produced and consumed
+// incrementally, so it works for heaps with millions of objects.
+func (p *Process) ExportGraph(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	var err error
+	emit := func(v any) bool {
+		if err == nil {
+			err = enc.Encode(v)
+		}
+		return err == nil
+	}
+	objID := func(x Object) string {
+		return fmt.Sprintf("%#x", p.Addr(x))
+	}
+
+	p.ForEachRoot(func(r *Root) bool {
+		id := fmt.Sprintf("r%d", r.id)
+		if !emit(exportRoot{Kind: "root", ID: id, Name: r.Name, Type: r.Type.Name}) {
+			return false
+		}
+		p.ForEachRootPtr(r, func(i int64, y Object, j int64) bool {
+			return emit(exportEdge{Kind: "edge", From: id, Off: i, To: objID(y), ToOff: j})
+		})
+		return err == nil
+	})
+	p.ForEachObject(func(x Object) bool {
+		id := objID(x)
+		rec := exportObject{Kind: "object", ID: id, Size: p.Size(x)}
+		if t, r := p.Type(x); t != nil {
+			rec.Type, rec.Repeat = t.Name, r
+		}
+		if !emit(rec) {
+			return false
+		}
+		p.ForEachPtr(x, func(i int64, y Object, j int64) bool {
+			return emit(exportEdge{Kind: "edge", From: id, Off: i, To: objID(y), ToOff: j})
+		})
+		return err == nil
+	})
+	if err != nil {
+		return err
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,98 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import "golang.org/x/debug/internal/core"
+
+// A PendingFinalizer is a finalizer or cleanup that is ready to run
+// because its object became unreachable, but hasn't finished running.
+type PendingFinalizer struct {
+	Kind string // "Finalizer" or "Cleanup", as for a Special
+
+	// Obj is the object a finalizer is to be called with. For a
+	// cleanup, whose object has been freed, it is the copy of the
+	// argument passed to runtime.AddCleanup. In Go 1.25, which queues
+	// only a closure that calls the cleanup function with its argument,
+	// it is that closure, and Fn is the runtime's wrapper function.
+	Obj core.Address
+
+	Fn *Func // function to run, or nil if it isn't known
+}
+
+// PendingFinalizers returns the finalizers and cleanups queued to run,
+// including any finalizer that is running, but not a running cleanup,
+// which the runtime forgets as it calls it. Finalizers run one at a
+// time on the runtime's finalizer goroutine, so a long queue of them
+// usually means one of them is blocked or slow. Until they run, their
+// objects, and anything those reach, can't be freed.
+func (p *Process) PendingFinalizers() []PendingFinalizer {
+	var pending []PendingFinalizer
+
+	// The finalizer goroutine takes the whole queue (runtime.finq) at
+	// once, then runs and clears each block's finalizers from last to
+	// first. Blocks are never freed, so looking at all of them finds
+	// both the queued finalizers and the taken ones left to run.
+	if allfin, ok := p.rtGlobals["allfin"]; ok && p.rtTypeByName["runtime.finBlock"] != nil {
+		for b := allfin; b.Address() != 0; b = b.Deref().Field("alllink") {
+			blk := b.Deref()
+			fins := blk.Field("fin")
+			for i := int64(0); i < int64(blk.Field("cnt").Uint32()); i++ {
+				f := fins.ArrayIndex(i)
+				pending = append(pending, PendingFinalizer{
+					Kind: "Finalizer",
+					Obj:  f.Field("arg").Address(),
+					Fn:   p.funcvalFunc(f.Field("fn").Address()),
+				})
+			}
+		}
+	}
+
+	// Since Go 1.25, cleanups are queued separately, on runtime.gcCleanups,
+	// and may be run by several goroutines. A block's cleanups are cleared
+	// as they start. The list of all blocks, gcCleanups.all, is a
+	// *cleanupBlock wrapped in an atomic.UnsafePointer.
+	q, ok := p.rtGlobals["gcCleanups"]
+	blockType := p.rtTypeByName["runtime.cleanupBlock"]
+	if ok && blockType != nil {
+		for a := p.proc.ReadPtr(q.Field("all").a); a != 0; {
+			blk := region{p: p.proc, a: a, typ: blockType}
+			a = blk.Field("cleanupBlockHeader").Field("alllink").Address()
+			cleanups := blk.Field("cleanups")
+			n := int64(blk.Field("cleanupBlockHeader").Field("n").Uint32())
+			for i := int64(0); i < n; i++ {
+				if f, ok := p.pendingCleanup(cleanups.ArrayIndex(i)); ok {
+					pending = append(pending, f)
+				}
+			}
+		}
+	}
+	return pending
+}
+
+// pendingCleanup returns the cleanup c, an element of a
+// runtime.cleanupBlock's cleanups, and whether there is one: the
+// element is cleared as the cleanup starts. In Go 1.25, an element is
+// the *funcval of a closure calling the cleanup function with its
+// argument; since Go 1.26, a runtime.cleanupFn holding both.
+func (p *Process) pendingCleanup(c region) (PendingFinalizer, bool) {
+	switch {
+	case c.typ.Kind == KindPtr:
+		fv := c.Address()
+		return PendingFinalizer{Kind: "Cleanup", Obj: fv, Fn: p.funcvalFunc(fv)}, fv != 0
+	case c.typ.Kind == KindStruct && c.HasField("fn") && c.HasField("arg"):
+		fv := c.Field("fn").Address()
+		return PendingFinalizer{Kind: "Cleanup", Obj: c.Field("arg").Address(), Fn: p.funcvalFunc(fv)}, fv != 0
+	}
+	return PendingFinalizer{}, false
+}
+
+// funcvalFunc returns the function of the func value fv, a
+// *runtime.funcval, or nil if fv is nil or its function is unknown.
+func (p *Process) funcvalFunc(fv core.Address) *Func {
+	if fv == 0 {
+		return nil
+	}
+	return p.funcTab.find(p.proc.ReadPtr(fv))
+}
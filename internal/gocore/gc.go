@@ -0,0 +1,82 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"math"
+	"time"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// GCStats describes the state of the garbage collector when the core
+// was taken. Fields the inferior's runtime doesn't record are zero.
+type GCStats struct {
+	NumGC       uint32        // number of completed GC cycles
+	LastGC      time.Time     // when the last cycle finished; zero if none has
+	PauseTotal  time.Duration // total stop-the-world pause time
+	GCPercent   int32         // the GOGC setting; negative if GC is off
+	MemoryLimit int64         // the GOMEMLIMIT setting, in bytes
+	Running     bool          // a cycle is in progress
+
+	// Heap sizes, in bytes, as the GC pacer sees them.
+	HeapLive   uint64 // marked by the last cycle, plus allocated since
+	HeapMarked uint64 // marked by the last cycle
+	HeapGoal   uint64 // heap size GCPercent aims for; MemoryLimit may lower it
+	Trigger    uint64 // HeapLive when the running cycle started; 0 if not Running
+
+	AssistRatio float64 // units of scan work mutator assists do per byte allocated
+}
+
+// GCStats returns the state of the garbage collector, from the
+// runtime's GC pacer and memory statistics.
+func (p *Process) GCStats() GCStats {
+	var s GCStats
+	// field returns the address of the named field of r, if it has it.
+	// The runtime wraps some of them in atomic types, whose value is
+	// at offset 0.
+	field := func(r region, name string) (core.Address, bool) {
+		if !r.HasField(name) {
+			return 0, false
+		}
+		return r.Field(name).a, true
+	}
+	if m, ok := p.rtGlobals["memstats"]; ok {
+		s.NumGC = m.Field("numgc").Uint32()
+		if t := m.Field("last_gc_unix").Uint64(); t != 0 {
+			s.LastGC = time.Unix(0, int64(t))
+		}
+		s.PauseTotal = time.Duration(m.Field("pause_total_ns").Uint64())
+	}
+	if phase, ok := p.rtGlobals["gcphase"]; ok {
+		s.Running = phase.Uint32() != 0 // _GCoff
+	}
+	c, ok := p.rtGlobals["gcController"]
+	if !ok {
+		return s
+	}
+	if a, ok := field(c, "gcPercent"); ok {
+		s.GCPercent = p.proc.ReadInt32(a)
+	}
+	if a, ok := field(c, "memoryLimit"); ok {
+		s.MemoryLimit = p.proc.ReadInt64(a)
+	}
+	if a, ok := field(c, "heapLive"); ok {
+		s.HeapLive = p.proc.ReadUint64(a)
+	}
+	if a, ok := field(c, "heapMarked"); ok {
+		s.HeapMarked = p.proc.ReadUint64(a)
+	}
+	if a, ok := field(c, "gcPercentHeapGoal"); ok {
+		s.HeapGoal = p.proc.ReadUint64(a)
+	}
+	if a, ok := field(c, "triggered"); ok && s.Running {
+		s.Trigger = p.proc.ReadUint64(a)
+	}
+	if a, ok := field(c, "assistWorkPerByte"); ok {
+		s.AssistRatio = math.Float64frombits(p.proc.ReadUint64(a))
+	}
+	return s
+}
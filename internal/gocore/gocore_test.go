@@ -7,15 +7,22 @@
 package gocore
 
 import (
+	"bufio"
 	"bytes"
 	"cmp"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"slices"
 	"strings"
+	"sync"
 	"testing"
 
 	"golang.org/x/debug/internal/core"
@@ -29,16 +36,30 @@ func loadCore(t *testing.T, corePath, base, exePath string) *Process {
 	if err != nil {
 		t.Fatalf("can't load test core file: %s", err)
 	}
-	p, err := Core(c)
+	p, err := CoreWithOptions(c, Options{Flags: FlagStrictTypes})
 	if err != nil {
 		t.Fatalf("can't parse Go core: %s", err)
 	}
+	for _, w := range p.Warnings() {
+		if strings.HasPrefix(w, "conflicting DWARF types") {
+			t.Errorf("warning: %s", w)
+		} else {
+			t.Logf("warning: %s", w)
+		}
+	}
 	return p
 }
 
 // loadExampleGenerated generates a core from a binary built with
 // runtime.GOROOT().
 func loadExampleGenerated(t *testing.T, buildFlags, env []string) *Process {
+	t.Helper()
+	return loadCore(t, generateExampleCore(t, buildFlags, env), "", "")
+}
+
+// generateExampleCore is like loadExampleGenerated, but returns the
+// path of the core file instead of loading it.
+func generateExampleCore(t *testing.T, buildFlags, env []string) string {
 	t.Helper()
 	testenv.MustHaveGoBuild(t)
 	switch runtime.GOOS {
@@ -62,7 +83,7 @@ func loadExampleGenerated(t *testing.T, buildFlags, env []string) *Process {
 	if err != nil {
 		t.Fatalf("generateCore() got err %v want nil", err)
 	}
-	return loadCore(t, file, "", "")
+	return file
 }
 
 func setupCorePattern(t *testing.T) func() {
@@ -216,12 +237,171 @@ func checkProcess(t *testing.T, p *Process) {
 		t.Error("len(p.Goroutines()) == 0, want >0")
 	}
 
+	for _, g := range p.Goroutines() {
+		if err := g.UnwindError(); err != nil {
+			t.Errorf("goroutine %d: %v", g.ID(), err)
+		}
+		for _, f := range g.Frames() {
+			if f.Min() == f.Max() {
+				continue
+			}
+			kind, cg, cf := p.Classify(f.Min())
+			if kind != AddrStack || cg != g || cf != f {
+				t.Errorf("Classify(%#x) = %s, %p, %p, want stack, %p, %p", f.Min(), kind, cg, cf, g, f)
+			}
+		}
+	}
+
+	for _, w := range p.Warnings() {
+		if strings.Contains(w, "inline mark bits") {
+			t.Errorf("unexpected warning: %s", w)
+		}
+	}
+
 	const heapName = "heap"
 	heapStat := p.Stats().Sub(heapName)
 	if heapStat == nil || heapStat.Value == 0 {
 		t.Errorf("stat[%q].Size == 0, want >0", heapName)
 	}
 
+	for _, name := range []string{"data", "bss"} {
+		if s := p.Stats().Sub(name); s == nil || s.Value == 0 {
+			t.Errorf("stat[%q].Size == 0, want >0", name)
+		}
+	}
+	var mapped int64
+	for _, m := range p.Process().Mappings() {
+		if m.Perm()&core.Read != 0 {
+			mapped += m.Size()
+		}
+	}
+	if all := p.Stats().Value; all > mapped {
+		t.Errorf("stats add up to %d bytes, more than the %d bytes mapped", all, mapped)
+	}
+
+	if len(p.SpanStats()) == 0 {
+		t.Error("len(p.SpanStats()) == 0, want >0")
+	}
+	for _, cs := range p.SpanStats() {
+		if cs.Alloc > cs.Slots {
+			t.Errorf("span class %d: %d slots allocated out of %d", cs.Class, cs.Alloc, cs.Slots)
+		}
+		if cs.ElemSize != 0 && cs.Slots*cs.ElemSize+cs.Tail != cs.Bytes {
+			t.Errorf("span class %d: %d slots of %d bytes + %d tail bytes != %d bytes", cs.Class, cs.Slots, cs.ElemSize, cs.Tail, cs.Bytes)
+		}
+		var spans int64
+		for _, c := range cs.Occupancy {
+			spans += c
+		}
+		if spans != cs.Spans {
+			t.Errorf("span class %d: occupancy histogram counts %d spans, want %d", cs.Class, spans, cs.Spans)
+		}
+	}
+	perClass := map[uint8]int64{}
+	var prev core.Address
+	p.ForEachSpan(func(s SpanInfo) bool {
+		if s.Base < prev {
+			t.Errorf("ForEachSpan: span %x after %x", s.Base, prev)
+		}
+		prev = s.Base
+		perClass[s.Class] += s.Size
+		return true
+	})
+	for _, cs := range p.SpanStats() {
+		if perClass[cs.Class] != cs.Bytes {
+			t.Errorf("span class %d: ForEachSpan found %d bytes of spans, SpanStats %d", cs.Class, perClass[cs.Class], cs.Bytes)
+		}
+	}
+
+	n := 0
+	p.ForEachObject(func(x Object) bool {
+		count := func(each func(Object, func(Object, *Root, int64, int64) bool)) int {
+			c := 0
+			each(x, func(Object, *Root, int64, int64) bool {
+				c++
+				return true
+			})
+			return c
+		}
+		if scan, idx := count(p.ScanReversePtrs), count(p.ForEachReversePtr); scan != idx {
+			t.Errorf("object %x: ScanReversePtrs found %d pointers, ForEachReversePtr found %d", p.Addr(x), scan, idx)
+		}
+		if s := p.ObjectSpan(x); p.Addr(x) < s.Base || p.Addr(x) >= s.Base.Add(s.Size) || s.ElemSize != p.Size(x) || s.Alloc == 0 {
+			t.Errorf("object %x of size %d: ObjectSpan = %+v", p.Addr(x), p.Size(x), s)
+		}
+		for _, off := range []int64{0, p.Size(x) / 2, p.Size(x) - 1} {
+			if y, yoff := p.FindObject(p.Addr(x).Add(off)); y != x || yoff != off {
+				t.Errorf("FindObject(%x+%d) = %x, %d, want %x, %d", p.Addr(x), off, p.Addr(y), yoff, p.Addr(x), off)
+			}
+		}
+		if b, complete := p.ReadObject(x); int64(len(b)) != p.Size(x) {
+			t.Errorf("object %x: ReadObject returned %d bytes, want %d", p.Addr(x), len(b), p.Size(x))
+		} else if complete {
+			want := make([]byte, len(b))
+			p.Process().ReadAt(want, p.Addr(x))
+			if !bytes.Equal(b, want) {
+				t.Errorf("object %x: ReadObject = %x, want %x", p.Addr(x), b, want)
+			}
+		}
+		n++
+		return n < 10
+	})
+
+	var buf bytes.Buffer
+	if err := p.ExportGraph(&buf); err != nil {
+		t.Errorf("ExportGraph: %v", err)
+	}
+	kinds := map[string]int{}
+	for dec := json.NewDecoder(&buf); dec.More(); {
+		var rec struct{ Kind string }
+		if err := dec.Decode(&rec); err != nil {
+			t.Fatalf("decoding ExportGraph output: %v", err)
+		}
+		kinds[rec.Kind]++
+	}
+	nObj := 0
+	var live int64
+	var last core.Address
+	shallow := map[*Type]int64{}
+	p.ForEachObject(func(x Object) bool {
+		if p.Addr(x) <= last {
+			t.Errorf("ForEachObject visited %x after %x", p.Addr(x), last)
+		}
+		last = p.Addr(x)
+		nObj++
+		live += p.Size(x)
+		if typ, _ := p.Type(x); typ != nil {
+			shallow[typ] += p.Size(x)
+		}
+		return true
+	})
+	if kinds["object"] != nObj {
+		t.Errorf("ExportGraph wrote %d objects, want %d", kinds["object"], nObj)
+	}
+	if got := p.NumObjects(); got != nObj {
+		t.Errorf("NumObjects() = %d, want %d", got, nObj)
+	}
+	if got := p.LiveBytes(); got != live {
+		t.Errorf("LiveBytes() = %d, want %d", got, live)
+	}
+
+	types := map[*Type]bool{}
+	for _, typ := range p.Types() {
+		types[typ] = true
+	}
+	for typ := range shallow {
+		if !types[typ] {
+			t.Errorf("heap type %s missing from Types()", typ)
+		}
+	}
+
+	retained := p.RetainedByType()
+	for typ, size := range shallow {
+		if r := retained[typ]; r < size || r > live {
+			t.Errorf("RetainedByType()[%s] = %d, want between %d and %d", typ, r, size, live)
+		}
+	}
+
 	lt := runLT(p)
 	if !checkDominator(t, lt) {
 		t.Errorf("sanityCheckDominator(...) = false, want true")
@@ -321,6 +501,18 @@ func TestObjects(t *testing.T) {
 	})
 }
 
+// findGlobal returns the root of the global variable name.
+func findGlobal(t *testing.T, p *Process, name string) *Root {
+	t.Helper()
+	for _, r := range p.Globals() {
+		if r.Name == name {
+			return r
+		}
+	}
+	t.Fatalf("global %s not found", name)
+	return nil
+}
+
 // typeName returns a string representing the type of this object.
 func typeName(c *Process, x Object) string {
 	size := c.Size(x)
@@ -339,3 +531,1330 @@ func typeName(c *Process, x Object) string {
 	}
 	return name
 }
+
+func TestAncestors(t *testing.T) {
+	p := loadExampleGenerated(t, nil, []string{"GODEBUG=tracebackancestors=10"})
+	found := false
+	for _, g := range p.Goroutines() {
+		// Look for the goroutine started by the closure in main.main.
+		var closure bool
+		for _, f := range g.Frames() {
+			if f.Func().Name() == "main.main.func1" {
+				closure = true
+			}
+		}
+		if !closure {
+			continue
+		}
+		found = true
+		anc := g.Ancestors()
+		if len(anc) != 1 {
+			t.Fatalf("goroutine running main.main.func1 has %d ancestors, want 1", len(anc))
+		}
+		var names []string
+		for _, f := range anc[0] {
+			names = append(names, f.Func().Name())
+		}
+		if !slices.Contains(names, "main.main") {
+			t.Errorf("ancestor stack %v does not contain main.main", names)
+		}
+	}
+	if !found {
+		t.Errorf("no goroutine running main.main.func1")
+	}
+}
+
+func TestLocals(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var locals []LocalVar
+	var closure *Frame
+	for _, g := range p.Goroutines() {
+		for _, f := range g.Frames() {
+			locals = append(locals, f.Locals()...)
+			if f.Func().Name() == "main.main.func1" {
+				closure = f
+			}
+		}
+	}
+	if len(locals) == 0 {
+		// Variables are only read from .debug_loc, which DWARF 5 replaced.
+		t.Skip("no local variables found")
+	}
+	for _, l := range locals {
+		if l.Addr != l.Root.Addr() {
+			t.Errorf("local %s: Addr = %#x, Root.Addr() = %#x", l.Name, l.Addr, l.Root.Addr())
+		}
+		if b := p.ReadRoot(l.Root); int64(len(b)) != l.Type.Size {
+			t.Errorf("local %s: ReadRoot returned %d bytes, want %d", l.Name, len(b), l.Type.Size)
+		}
+	}
+	if closure == nil {
+		t.Fatal("no frame for main.main.func1")
+	}
+	for _, l := range closure.Locals() {
+		if l.Name == "typeSafeTree" {
+			if got, want := l.Type.Name, "main.TypeSafeTree[main.myPair]"; got != want {
+				t.Errorf("typeSafeTree has type %s, want %s", got, want)
+			}
+			return
+		}
+	}
+	t.Errorf("main.main.func1 has no local typeSafeTree")
+}
+
+func TestCoreSubgraph(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	sub, err := CoreSubgraph(p.Process(), func(r *Root) bool {
+		return r.Name == "main.globalTypeSafeTree"
+	})
+	if err != nil {
+		t.Fatalf("CoreSubgraph: %v", err)
+	}
+	n, nodes := 0, 0
+	sub.ForEachObject(func(x Object) bool {
+		n++
+		if typeName(sub, x) == "main.typeSafeNode[main.myPair]" {
+			nodes++
+		}
+		if y, off := p.FindObject(sub.Addr(x)); y != x || off != 0 {
+			t.Errorf("object %x found in subgraph but not in full heap", sub.Addr(x))
+		}
+		return true
+	})
+	// The tree has depth 5, and each node has a *myPair entry.
+	const nodesWant = 1<<5 - 1
+	if nodes != nodesWant {
+		t.Errorf("found %d main.typeSafeNode[main.myPair] objects, want %d", nodes, nodesWant)
+	}
+	if n != 2*nodesWant {
+		t.Errorf("found %d objects, want %d", n, 2*nodesWant)
+	}
+}
+
+func TestGoroutineState(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	for _, g := range p.Goroutines() {
+		for i, f := range g.Frames() {
+			if f.Func().Name() != "main.main.func1" {
+				continue
+			}
+			// The goroutine is blocked on <-block.
+			if g.Status() != "waiting" || g.WaitReason() != "chan receive" {
+				t.Errorf("goroutine %d: status %q, wait reason %q, want waiting on chan receive", g.ID(), g.Status(), g.WaitReason())
+			}
+			pc := f.PC()
+			if i > 0 {
+				pc--
+			}
+			if file, line := f.Func().FileLine(pc); filepath.Base(file) != "test.go" || line <= 0 {
+				t.Errorf("main.main.func1 is at %s:%d, want test.go", file, line)
+			}
+			return
+		}
+	}
+	t.Errorf("no goroutine running main.main.func1")
+}
+
+func TestBuildInfo(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	bi, err := p.BuildInfo()
+	if err != nil {
+		t.Fatalf("BuildInfo: %v", err)
+	}
+	if bi.GoVersion != p.BuildVersion() {
+		t.Errorf("GoVersion = %q, want %q", bi.GoVersion, p.BuildVersion())
+	}
+	// The test program is built from a file, not a package.
+	if bi.Path != "command-line-arguments" {
+		t.Errorf("Path = %q, want command-line-arguments", bi.Path)
+	}
+	settings := make(map[string]string)
+	for _, s := range bi.Settings {
+		settings[s.Key] = s.Value
+	}
+	if settings["GOOS"] != runtime.GOOS || settings["GOARCH"] != runtime.GOARCH {
+		t.Errorf("build settings GOOS=%q GOARCH=%q, want %s/%s", settings["GOOS"], settings["GOARCH"], runtime.GOOS, runtime.GOARCH)
+	}
+}
+
+func TestSyncState(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	addr := func(name string) core.Address {
+		return findGlobal(t, p, name).Addr()
+	}
+	if locked, waiters, ok := p.MutexState(addr("main.globalMutex")); !locked || waiters != 0 || !ok {
+		t.Errorf("MutexState = %t, %d, %t, want true, 0, true", locked, waiters, ok)
+	}
+	if s, ok := p.RWMutexState(addr("main.globalRWMutex")); s != (RWMutexState{Readers: 2}) || !ok {
+		t.Errorf("RWMutexState = %+v, %t, want 2 readers", s, ok)
+	}
+	if counter, waiters, ok := p.WaitGroupState(addr("main.globalWaitGroup")); counter != 3 || waiters != 0 || !ok {
+		t.Errorf("WaitGroupState = %d, %d, %t, want 3, 0, true", counter, waiters, ok)
+	}
+
+	// Without the types, as in stripped binaries, there's no state.
+	delete(p.rtTypeByName, "sync.WaitGroup")
+	if _, _, ok := p.WaitGroupState(addr("main.globalWaitGroup")); ok {
+		t.Error("WaitGroupState without sync.WaitGroup's type succeeded")
+	}
+}
+
+func TestSpecials(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	ptr := func(name string) core.Address {
+		return p.Process().ReadPtr(findGlobal(t, p, name).Addr())
+	}
+	for _, test := range []struct {
+		global, kind string
+	}{
+		{"main.globalFinalized", "Finalizer"},
+		{"main.globalCleanedUp", "Cleanup"},
+	} {
+		obj := ptr(test.global)
+		found := false
+		p.ForEachSpecial(func(s Special) bool {
+			if s.Obj == obj && s.Kind == test.kind {
+				found = true
+				return false
+			}
+			return true
+		})
+		if !found {
+			t.Errorf("no %s special for %s (%x)", test.kind, test.global, obj)
+		}
+		root := fmt.Sprintf("%s for %x", strings.ToLower(test.kind), obj)
+		if !slices.ContainsFunc(p.Globals(), func(r *Root) bool { return r.Name == root }) {
+			t.Errorf("no root %q", root)
+		}
+	}
+}
+
+func TestWriteHeapDump(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var buf bytes.Buffer
+	if err := p.WriteHeapDump(&buf); err != nil {
+		t.Fatalf("WriteHeapDump: %v", err)
+	}
+	r := bufio.NewReader(&buf)
+	hdr := make([]byte, len(dumpHeader))
+	if _, err := io.ReadFull(r, hdr); err != nil || string(hdr) != dumpHeader {
+		t.Fatalf("header = %q, %v, want %q", hdr, err, dumpHeader)
+	}
+	var err error
+	num := func() uint64 {
+		v, e := binary.ReadUvarint(r)
+		if err == nil {
+			err = e
+		}
+		return v
+	}
+	skip := func(n int) {
+		for range n {
+			num()
+		}
+	}
+	str := func() []byte {
+		b := make([]byte, num())
+		if _, e := io.ReadFull(r, b); err == nil {
+			err = e
+		}
+		return b
+	}
+	// fields reads a field list. Stack frames pass size -1: like the
+	// runtime, they report pointer arguments, which are in the
+	// caller's frame.
+	fields := func(size int) {
+		for num() == dumpFieldPtr {
+			if off := num(); size >= 0 && off >= uint64(size) {
+				t.Errorf("pointer field at offset %d of a %d-byte record", off, size)
+			}
+		}
+	}
+
+	tags := map[uint64]int{}
+	for tag := num(); err == nil && tag != dumpEOF; tag = num() {
+		tags[tag]++
+		switch tag {
+		case dumpObject, dumpData, dumpBSS:
+			num()
+			fields(len(str()))
+		case dumpGoroutine:
+			skip(8)
+			str()
+			skip(4)
+		case dumpStackFrame:
+			skip(3)
+			str()
+			skip(3)
+			str()
+			fields(-1)
+		case dumpParams:
+			skip(4)
+			str()
+			str()
+			num()
+		case dumpFinalizer:
+			skip(5)
+		case dumpOSThread:
+			skip(3)
+		case dumpMemStats:
+			skip(dumpMemStatsLen + 256 + 1)
+		default:
+			t.Fatalf("unexpected record tag %d", tag)
+		}
+	}
+	if err != nil {
+		t.Fatalf("reading heap dump: %v", err)
+	}
+	if _, err := r.ReadByte(); err != io.EOF {
+		t.Errorf("data after EOF record")
+	}
+
+	nObj := 0
+	p.ForEachObject(func(x Object) bool {
+		nObj++
+		return true
+	})
+	for _, c := range []struct {
+		tag  uint64
+		want int
+	}{
+		{dumpParams, 1},
+		{dumpObject, nObj},
+		{dumpGoroutine, len(p.Goroutines())},
+		{dumpMemStats, 1},
+	} {
+		if tags[c.tag] != c.want {
+			t.Errorf("got %d records with tag %d, want %d", tags[c.tag], c.tag, c.want)
+		}
+	}
+	for _, tag := range []uint64{dumpStackFrame, dumpData, dumpBSS, dumpFinalizer, dumpOSThread} {
+		if tags[tag] == 0 {
+			t.Errorf("no records with tag %d", tag)
+		}
+	}
+}
+
+func TestCrashingGoroutine(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	g, pan := p.CrashingGoroutine()
+	if g == nil {
+		t.Fatal("CrashingGoroutine() = nil, want the main goroutine")
+	}
+	if g.ID() != 1 {
+		t.Errorf("CrashingGoroutine() = goroutine %d, want goroutine 1", g.ID())
+	}
+	if pan == nil {
+		t.Fatal("CrashingGoroutine() returned no panic")
+	}
+	const want = "runtime error: invalid memory address or nil pointer dereference"
+	if pan.Type == nil || pan.Message != want {
+		t.Errorf("panic value is %s %q, want runtime.errorString %q", pan.Type, pan.Message, want)
+	}
+
+	// Read the panic's string, and its length, as values of other
+	// types. Before printing it, the runtime may have replaced an
+	// error by its message.
+	msg := want
+	if pan.Type.Name == "runtime.errorString" {
+		msg = strings.TrimPrefix(want, "runtime error: ")
+	}
+	for _, tc := range []struct {
+		typ  *Type
+		a    core.Address
+		want string
+	}{
+		{&Type{Name: "string", Size: 16, Kind: KindString}, pan.Addr, msg},
+		{&Type{Name: "runtime.plainError", Size: 16, Kind: KindString}, pan.Addr, msg},
+		{&Type{Name: "main.T", Size: 16, Kind: KindString}, pan.Addr, `main.T("` + msg + `")`},
+		{&Type{Name: "int", Size: 8, Kind: KindInt}, pan.Addr.Add(8), fmt.Sprint(len(msg))},
+		{&Type{Name: "main.N", Size: 8, Kind: KindInt}, pan.Addr.Add(8), fmt.Sprintf("main.N(%d)", len(msg))},
+		{&Type{Name: "main.U", Size: 1, Kind: KindUint}, pan.Addr.Add(8), fmt.Sprintf("main.U(%d)", len(msg))},
+	} {
+		if got := p.panicMessage(tc.typ, tc.a); got != tc.want {
+			t.Errorf("panicMessage(%s) = %q, want %q", tc.typ, got, tc.want)
+		}
+	}
+}
+
+func TestAllocProfile(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	// main.makeProfiled allocates 10 profObjs with every allocation
+	// sampled, and keeps them live.
+	var got int64
+	for _, r := range p.AllocProfile() {
+		if len(r.Stack) == 0 {
+			t.Errorf("allocation record with no stack")
+			continue
+		}
+		if f := p.FindFunc(r.Stack[0] - 1); f != nil && f.Name() == "main.makeProfiled" && r.Size == 16 {
+			got += r.InUseObjects()
+		}
+	}
+	if got != 10 {
+		t.Errorf("got %d objects in use allocated by main.makeProfiled, want 10", got)
+	}
+}
+
+func TestNetPollers(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	// main.startPipeReader starts a goroutine reading from a pipe.
+	// Both ends are registered with the poller.
+	var reader *Goroutine
+	pollers := p.NetPollers()
+	for _, np := range pollers {
+		if np.Reader != nil {
+			reader = np.Reader
+		}
+		if np.Writer != nil {
+			t.Errorf("fd %d: goroutine %d is blocked writing", np.FD, np.Writer.ID())
+		}
+	}
+	if len(pollers) < 2 {
+		t.Errorf("got %d file descriptors registered with the poller, want at least 2", len(pollers))
+	}
+	if reader == nil {
+		t.Fatal("no goroutine is blocked reading")
+	}
+	found := false
+	for _, f := range reader.Frames() {
+		found = found || f.Func().Name() == "main.startPipeReader.func1"
+	}
+	if !found {
+		t.Errorf("goroutine %d blocked reading isn't main.startPipeReader's", reader.ID())
+	}
+}
+
+func TestTraceData(t *testing.T) {
+	p := loadExampleGenerated(t, nil, []string{"GO_DEBUG_TEST_TRACE=1"})
+	// main.startTracing leaves tracing on.
+	data, err := p.TraceData()
+	if err != nil {
+		t.Fatal(err)
+	}
+	header := "go 1."
+	if !bytes.HasPrefix(data, []byte(header)) {
+		t.Fatalf("trace data starts with %q, want %q", data[:min(len(data), 16)], header)
+	}
+	// Check that the data splits into whole batches. See
+	// internal/trace/batch.go.
+	const (
+		evEventBatch        = 1
+		evExperimentalBatch = 49
+		evEndOfGeneration   = 52
+	)
+	r := bytes.NewReader(data[16:])
+	batches := 0
+	for r.Len() > 0 {
+		typ, _ := r.ReadByte()
+		switch typ {
+		case evEndOfGeneration:
+			continue
+		case evExperimentalBatch:
+			r.ReadByte()
+		case evEventBatch:
+		default:
+			t.Fatalf("batch %d: got event %d, want a batch", batches, typ)
+		}
+		var hdr [4]uint64 // gen, M, timestamp, size
+		for i := range hdr {
+			v, err := binary.ReadUvarint(r)
+			if err != nil {
+				t.Fatalf("batch %d: reading header: %v", batches, err)
+			}
+			hdr[i] = v
+		}
+		if hdr[3] > uint64(r.Len()) {
+			t.Fatalf("batch %d: size %d, but only %d bytes left", batches, hdr[3], r.Len())
+		}
+		r.Seek(int64(hdr[3]), io.SeekCurrent)
+		batches++
+	}
+	if batches == 0 {
+		t.Error("no batches in trace data")
+	}
+}
+
+func TestPendingFinalizers(t *testing.T) {
+	p := loadExampleGenerated(t, nil, []string{"GO_DEBUG_TEST_FINALIZERS=1"})
+	// main.queueFinalizers leaves 5 finalizers and 5 cleanups queued,
+	// each running or blocked behind one that is. The running cleanup
+	// isn't pending.
+	want := map[string]string{
+		"Finalizer": "main.queueFinalizers.func1",
+		"Cleanup":   "main.queueFinalizers.func2",
+	}
+	n := map[string]int{}
+	for _, f := range p.PendingFinalizers() {
+		if f.Fn == nil || f.Fn.Name() != want[f.Kind] {
+			continue
+		}
+		n[f.Kind]++
+		if f.Obj == 0 {
+			t.Errorf("pending %s %s has no object", f.Kind, f.Fn.Name())
+		}
+	}
+	if n["Finalizer"] != 5 || n["Cleanup"] != 4 {
+		t.Errorf("got %d pending finalizers and %d cleanups, want 5 and 4", n["Finalizer"], n["Cleanup"])
+	}
+}
+
+// TestPendingCleanupFuncval checks that pendingCleanup reads the
+// cleanups of Go 1.25, a bare *funcval each, whatever the toolchain.
+func TestPendingCleanupFuncval(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	// main.globalAnyTreeFM holds a method value, a *funcval.
+	a := findGlobal(t, p, "main.globalAnyTreeFM").Addr()
+	fv := &Type{Name: "*runtime.funcval", Size: p.PtrSize(), Kind: KindPtr, Elem: &Type{Name: "runtime.funcval", Kind: KindStruct}}
+	c, ok := p.pendingCleanup(region{p: p.proc, a: a, typ: fv})
+	if !ok {
+		t.Fatal("no pending cleanup")
+	}
+	if c.Kind != "Cleanup" || c.Obj != p.proc.ReadPtr(a) || c.Fn == nil || !strings.HasSuffix(c.Fn.Name(), "-fm") {
+		t.Errorf("got %+v (func %v), want the cleanup of main.globalAnyTreeFM's method value", c, c.Fn)
+	}
+
+	// A cleared element isn't pending, nor is one of an unknown type.
+	if _, ok := p.pendingCleanup(region{p: p.proc, a: 0, typ: &Type{Name: "uintptr", Size: p.PtrSize(), Kind: KindUint}}); ok {
+		t.Error("pending cleanup of unknown type")
+	}
+}
+
+func TestStackInUse(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	for _, g := range p.Goroutines() {
+		if n := g.StackInUse(); n < 0 || n > g.Stack() {
+			t.Errorf("goroutine %d: StackInUse() = %d, want within [0, %d]", g.ID(), n, g.Stack())
+		}
+	}
+	// The background goroutines are parked with frames on their stacks.
+	n := 0
+	for _, g := range p.Goroutines() {
+		if fs := g.Frames(); len(fs) > 0 && fs[0].Func().Name() == "runtime.gopark" {
+			n++
+			if g.StackInUse() == 0 {
+				t.Errorf("goroutine %d: StackInUse() = 0 while parked", g.ID())
+			}
+		}
+	}
+	if n == 0 {
+		t.Errorf("no parked goroutines")
+	}
+}
+
+func TestStackMapFallback(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var fr *Frame
+	for _, g := range p.Goroutines() {
+		for _, f := range g.Frames() {
+			if len(f.Live) > 0 && !f.conservative {
+				fr = f
+				break
+			}
+		}
+		if fr != nil {
+			break
+		}
+	}
+	if fr == nil {
+		t.Fatal("no frame with live pointers")
+	}
+
+	// Without a stack map there are no pointers, and no error.
+	if _, nbit, err := readStackMap(p, fr.f, 0, 0); nbit != 0 || err != nil {
+		t.Errorf("readStackMap of no stack map = %d bits, %v; want 0 bits, nil", nbit, err)
+	}
+
+	// Lose the function's stack map index table. The frame should
+	// still be read, with no live pointers and a warning.
+	stackMap := fr.f.stackMap
+	defer func() { fr.f.stackMap = stackMap }()
+	fr.f.stackMap = pcTab{}
+	nw := len(p.warnings)
+	f, err := readFrame(p, fr.min, fr.pc)
+	if err != nil {
+		t.Fatalf("readFrame(%s) with no stack map index: %v", fr.f.name, err)
+	}
+	if f.min != fr.min || f.max != fr.max {
+		t.Errorf("frame of %s = [%x,%x), want [%x,%x)", fr.f.name, f.min, f.max, fr.min, fr.max)
+	}
+	if len(f.Live) != 0 {
+		t.Errorf("frame of %s has %d live pointers, want 0", fr.f.name, len(f.Live))
+	}
+	found := false
+	for _, w := range p.warnings[nw:] {
+		found = found || strings.Contains(w, "cannot read stack map")
+	}
+	if !found {
+		t.Errorf("no warning about the unreadable stack map of %s in %q", fr.f.name, p.warnings[nw:])
+	}
+}
+
+func TestWalkFramePointers(t *testing.T) {
+	// Two C frames, with frame pointers 0x1000 and 0x1040, called from
+	// Go. Go code is at [0x400000, 0x500000) and C code at 0x700000.
+	mem := map[core.Address]core.Address{
+		0x1000: 0x1040, 0x1008: 0x700010,
+		0x1040: 0x1080, 0x1048: 0x400123,
+	}
+	readPtr := func(a core.Address) (core.Address, bool) {
+		v, ok := mem[a]
+		return v, ok
+	}
+	isGo := func(pc core.Address) bool {
+		return pc >= 0x400000 && pc < 0x500000
+	}
+	for _, test := range []struct {
+		name    string
+		bp      core.Address
+		sp, pc  core.Address
+		changes map[core.Address]core.Address
+	}{
+		{name: "chain", bp: 0x1000, sp: 0x1050, pc: 0x400123},
+		{name: "go caller", bp: 0x1040, sp: 0x1050, pc: 0x400123},
+		{name: "no frame pointer", bp: 0},
+		{name: "unreadable", bp: 0x2000},
+		{name: "unreadable caller", bp: 0x1000, changes: map[core.Address]core.Address{0x1040: 0x3000, 0x1048: 0x700020}},
+		{name: "down the stack", bp: 0x1000, changes: map[core.Address]core.Address{0x1040: 0x1000, 0x1048: 0x700020}},
+	} {
+		saved := map[core.Address]core.Address{}
+		for a, v := range test.changes {
+			saved[a] = mem[a]
+			mem[a] = v
+		}
+		sp, pc := walkFramePointers(test.bp, 8, readPtr, isGo)
+		if sp != test.sp || pc != test.pc {
+			t.Errorf("%s: walkFramePointers(%#x) = %#x, %#x, want %#x, %#x", test.name, test.bp, sp, pc, test.sp, test.pc)
+		}
+		for a, v := range saved {
+			mem[a] = v
+		}
+	}
+}
+
+func TestFindFuncByName(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	f := p.FindFuncByName("main.main")
+	if f == nil {
+		t.Fatal("FindFuncByName(main.main) = nil")
+	}
+	if g := p.FindFunc(f.Entry()); g != f {
+		t.Errorf("FindFunc(main.main's entry) = %v, want main.main", g)
+	}
+	if f := p.FindFuncByName("main.noSuchFunc"); f != nil {
+		t.Errorf("FindFuncByName(main.noSuchFunc) = %s, want nil", f.Name())
+	}
+
+	mods := p.Modules()
+	if len(mods) != 1 {
+		t.Fatalf("got %d modules, want 1", len(mods))
+	}
+	found := false
+	var prev core.Address
+	for _, g := range mods[0].Funcs() {
+		if g.Entry() < prev {
+			t.Errorf("%s at %x comes after a function at %x", g.Name(), g.Entry(), prev)
+		}
+		prev = g.Entry()
+		found = found || g == f
+	}
+	if !found {
+		t.Errorf("main.main is not in the executable's module")
+	}
+}
+
+func TestGCStats(t *testing.T) {
+	p := loadExampleGenerated(t, nil, []string{"GOGC=50", "GOMEMLIMIT=1GiB"})
+	s := p.GCStats()
+	if s.GCPercent != 50 {
+		t.Errorf("GCPercent = %d, want 50", s.GCPercent)
+	}
+	if s.MemoryLimit != 1<<30 {
+		t.Errorf("MemoryLimit = %d, want %d", s.MemoryLimit, 1<<30)
+	}
+	if s.HeapGoal == 0 || s.HeapLive == 0 {
+		t.Errorf("HeapGoal = %d, HeapLive = %d, want >0", s.HeapGoal, s.HeapLive)
+	}
+	if s.NumGC > 0 && s.LastGC.IsZero() {
+		t.Errorf("LastGC is zero after %d cycles", s.NumGC)
+	}
+	if !s.Running && s.Trigger != 0 {
+		t.Errorf("Trigger = %d with no cycle running, want 0", s.Trigger)
+	}
+}
+
+func TestForEachRootPtrByKind(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	found := false
+	p.ForEachGlobalRootPtr(func(r *Root, i int64, y Object, j int64) bool {
+		if r.Frame != nil {
+			t.Errorf("global root pointer from stack root %s", r.Name)
+		}
+		if r.Name == "main.globalTypeSafeTree" && typeName(p, y) == "main.typeSafeNode[main.myPair]" {
+			found = true
+		}
+		return true
+	})
+	if !found {
+		t.Errorf("no pointer from main.globalTypeSafeTree to its root node")
+	}
+
+	for _, g := range p.Goroutines() {
+		n := 0
+		p.ForEachFrameRootPtr(g, func(r *Root, i int64, y Object, j int64) bool {
+			if r.Frame == nil || !slices.Contains(g.Frames(), r.Frame) {
+				t.Errorf("goroutine %d: frame root pointer from %s, which is not in its frames", g.ID(), r.Name)
+			}
+			n++
+			return false
+		})
+		if n > 1 {
+			t.Errorf("goroutine %d: ForEachFrameRootPtr continued after fn returned false", g.ID())
+		}
+	}
+}
+
+// TestConcurrentAccess exercises the lazily initialized parts of a
+// Process from several goroutines at once. It is most useful with -race.
+func TestConcurrentAccess(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var wg sync.WaitGroup
+	for range 4 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			p.ForEachRoot(func(r *Root) bool {
+				if r.HasAddress() && (r.Type.Kind == KindEface || r.Type.Kind == KindIface) {
+					p.DynamicType(r.Type, r.Addr())
+				}
+				return true
+			})
+			p.ForEachObject(func(x Object) bool {
+				p.Type(x)
+				p.ForEachReversePtr(x, func(Object, *Root, int64, int64) bool { return false })
+				return true
+			})
+			p.Types()
+		}()
+	}
+	wg.Wait()
+}
+
+func TestForEachObjectIn(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var all []Object
+	p.ForEachObject(func(x Object) bool {
+		all = append(all, x)
+		return true
+	})
+	if len(all) < 3 {
+		t.Fatalf("only %d objects", len(all))
+	}
+	collect := func(min, max core.Address) []Object {
+		var got []Object
+		p.ForEachObjectIn(min, max, func(x Object) bool {
+			got = append(got, x)
+			return true
+		})
+		return got
+	}
+	// An object starting just before min is not in the range, even
+	// though it overlaps it.
+	lo, hi := len(all)/3, 2*len(all)/3
+	min, max := p.Addr(all[lo]).Add(1), p.Addr(all[hi])
+	if got, want := collect(min, max), all[lo+1:hi]; !slices.Equal(got, want) {
+		t.Errorf("ForEachObjectIn(%x, %x) visited %d objects, want %d", min, max, len(got), len(want))
+	}
+	if got := collect(0, ^core.Address(0)); !slices.Equal(got, all) {
+		t.Errorf("ForEachObjectIn over all memory visited %d objects, want %d", len(got), len(all))
+	}
+	if got := collect(max, min); len(got) != 0 {
+		t.Errorf("ForEachObjectIn(%x, %x) visited %d objects, want 0", max, min, len(got))
+	}
+}
+
+func TestForEachGlobal(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	found := false
+	p.ForEachGlobal(func(name string, typ *Type, a core.Address) bool {
+		if name == "unk" || a == 0 {
+			t.Errorf("ForEachGlobal visited %s at %#x", name, a)
+		}
+		if name == "main.globalTypeSafeTree" {
+			found = true
+			if typ.Name != "main.TypeSafeTree[main.myPair]" {
+				t.Errorf("main.globalTypeSafeTree has type %s", typ)
+			}
+			if y, off := p.FindObject(p.Process().ReadPtr(a)); y == 0 || off != 0 {
+				t.Errorf("main.globalTypeSafeTree does not point to an object")
+			}
+		}
+		return true
+	})
+	if !found {
+		t.Errorf("main.globalTypeSafeTree not found")
+	}
+}
+
+func TestTypePtrs(t *testing.T) {
+	ptr := &Type{Name: "*int", Size: 8, Kind: KindPtr}
+	str := &Type{Name: "string", Size: 16, Kind: KindString}
+	pair := &Type{Name: "pair", Size: 32, Kind: KindStruct, Fields: []Field{
+		{Name: "a", Off: 0, Type: &Type{Name: "int", Size: 8, Kind: KindInt}},
+		{Name: "p", Off: 8, Type: ptr},
+		{Name: "s", Off: 16, Type: str},
+	}}
+	big := &Type{Name: "[1048576]*int", Size: 8 << 20, Kind: KindArray, Count: 1 << 20, Elem: ptr}
+	huge := &Type{Name: "[1000000000]uint8", Size: 1e9, Kind: KindArray, Count: 1e9, Elem: &Type{Name: "uint8", Size: 1, Kind: KindUint}}
+
+	if got, want := slices.Collect(pair.ptrs()), []int64{8, 16}; !slices.Equal(got, want) {
+		t.Errorf("ptrs of %s = %v, want %v", pair, got, want)
+	}
+	n := 0
+	for off := range big.ptrs() {
+		if off != int64(n)*8 {
+			t.Fatalf("pointer %d of %s is at %d, want %d", n, big, off, n*8)
+		}
+		n++
+	}
+	if n != 1<<20 {
+		t.Errorf("%s has %d pointers, want %d", big, n, 1<<20)
+	}
+	for off := range huge.ptrs() {
+		t.Errorf("%s has a pointer at %d", huge, off)
+	}
+	for off := range big.ptrs() {
+		if off != 0 {
+			t.Errorf("first pointer of %s at %d", big, off)
+		}
+		break
+	}
+}
+
+func TestFieldNameAt(t *testing.T) {
+	ptr := &Type{Name: "*int", Size: 8, Kind: KindPtr}
+	slice := &Type{Name: "[]int", Size: 24, Kind: KindSlice}
+	iface := &Type{Name: "any", Size: 16, Kind: KindEface}
+	arr := &Type{Name: "[2]any", Size: 32, Kind: KindArray, Count: 2, Elem: iface}
+	pair := &Type{Name: "pair", Size: 88, Kind: KindStruct, Fields: []Field{
+		{Name: "p", Off: 0, Type: ptr},
+		{Name: "s", Off: 8, Type: slice},
+		{Name: "a", Off: 32, Type: arr},
+		{Name: "c", Off: 64, Type: &Type{Name: "complex128", Size: 16, Kind: KindComplex}},
+	}}
+	for _, tc := range []struct {
+		off  int64
+		want string
+	}{
+		{0, ".p"},
+		{8, ".s.ptr"},
+		{16, ".s.len"},
+		{24, ".s.cap"},
+		{32, ".a[0].type"},
+		{56, ".a[1].data"},
+		{72, ".c.imag"},
+		{80, ".???"},
+	} {
+		if got := pair.FieldNameAt(tc.off); got != tc.want {
+			t.Errorf("%s.FieldNameAt(%d) = %q, want %q", pair, tc.off, got, tc.want)
+		}
+	}
+
+	empty := &Type{Name: "[4]struct {}", Kind: KindArray, Count: 4, Elem: &Type{Name: "struct {}", Kind: KindStruct}}
+	if got := empty.FieldNameAt(0); got != ".???" {
+		t.Errorf("%s.FieldNameAt(0) = %q, want %q", empty, got, ".???")
+	}
+}
+
+func TestFieldPath(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var node, array Object
+	p.ForEachObject(func(x Object) bool {
+		typ, r := p.Type(x)
+		switch {
+		case typ == nil:
+		case node == 0 && typ.Name == "main.typeSafeNode[main.myPair]":
+			node = x
+		case array == 0 && r > 1 && typ.Size == 8:
+			array = x
+		}
+		return node == 0 || array == 0
+	})
+	if node == 0 || array == 0 {
+		t.Fatal("can't find a typeSafeNode and an array of pointers")
+	}
+	if got := p.FieldPath(node, 8); got != ".right" {
+		t.Errorf("FieldPath(typeSafeNode, 8) = %q, want .right", got)
+	}
+	if got := p.FieldPath(array, 8); got != "[1]" {
+		t.Errorf("FieldPath(array, 8) = %q, want [1]", got)
+	}
+}
+
+func TestInterfaceValue(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	global := func(name string) (core.Address, *Type) {
+		r := findGlobal(t, p, name)
+		return r.Addr(), r.Type
+	}
+	field := func(typ *Type, name string) *Field {
+		for i := range typ.Fields {
+			if typ.Fields[i].Name == name {
+				return &typ.Fields[i]
+			}
+		}
+		t.Fatalf("%s has no field %s", typ, name)
+		return nil
+	}
+	check := func(what string, a core.Address, typ *Type, wantType string, wantIndirect bool) core.Address {
+		t.Helper()
+		concrete, data, indirect := p.InterfaceValue(a, typ)
+		if concrete == nil || concrete.Name != wantType || indirect != wantIndirect {
+			t.Fatalf("%s: InterfaceValue = %v, %x, %v, want %s, indirect %v", what, concrete, data, indirect, wantType, wantIndirect)
+		}
+		if !indirect && data != a.Add(p.PtrSize()) {
+			t.Errorf("%s: direct data at %x, want the data word at %x", what, data, a.Add(p.PtrSize()))
+		}
+		return data
+	}
+
+	// An eface holding a pointer, an *anyNode.
+	a, typ := global("main.globalAnyTree")
+	f := field(typ, "root")
+	node := p.proc.ReadPtr(check("eface", a.Add(f.Off), f.Type, "*main.anyNode", false))
+
+	// The node's interfaces hold myPairs, which don't fit in the data word.
+	concrete, _, _ := p.InterfaceValue(a.Add(f.Off), f.Type)
+	nt := concrete.Elem
+	for _, name := range []string{"entry1", "entry2"} {
+		f := field(nt, name)
+		data := check(name, node.Add(f.Off), f.Type, "main.myPair", true)
+		if x := p.proc.ReadInt64(data); x != 5 {
+			t.Errorf("%s: myPair.x = %d, want 5", name, x)
+		}
+	}
+
+	// An iface holding a pointer, a *ptrXer.
+	a, typ = global("main.globalXer")
+	data := check("iface", a, typ, "*main.ptrXer", false)
+	if x := p.proc.ReadInt64(p.proc.ReadPtr(data)); x != 0x58 {
+		t.Errorf("iface: ptrXer.x = %#x, want 0x58", x)
+	}
+
+	a, typ = global("main.globalNilXer")
+	if concrete, data, indirect := p.InterfaceValue(a, typ); concrete != nil || data != 0 || indirect {
+		t.Errorf("nil iface: InterfaceValue = %v, %x, %v, want nil, 0, false", concrete, data, indirect)
+	}
+}
+
+func TestSetTypeNameFormatter(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var typ *Type
+	for _, x := range p.Types() {
+		if x.Name == "main.typeSafeNode[main.myPair]" {
+			typ = x
+			break
+		}
+	}
+	if typ == nil {
+		t.Fatal("can't find main.typeSafeNode[main.myPair]")
+	}
+	if got := p.TypeName(typ); got != typ.Name {
+		t.Errorf("TypeName = %q, want %q", got, typ.Name)
+	}
+	p.SetTypeNameFormatter(func(s string) string {
+		return strings.ReplaceAll(s, "main.", "")
+	})
+	if got, want := p.TypeName(typ), "typeSafeNode[myPair]"; got != want {
+		t.Errorf("TypeName with formatter = %q, want %q", got, want)
+	}
+	if typ.Name != "main.typeSafeNode[main.myPair]" {
+		t.Errorf("formatter changed Type.Name to %q", typ.Name)
+	}
+	p.SetTypeNameFormatter(nil)
+	if got := p.TypeName(typ); got != typ.Name {
+		t.Errorf("TypeName after reset = %q, want %q", got, typ.Name)
+	}
+}
+
+func TestUnderlying(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	found := false
+	for _, typ := range p.Types() {
+		switch typ.Name {
+		case "time.Duration":
+			found = true
+			if u := typ.Underlying(); u.Name != "int64" || u.Kind != KindInt || u.Size != 8 {
+				t.Errorf("underlying type of time.Duration is %s (kind %s, size %d), want int64", u, u.Kind, u.Size)
+			}
+		case "int64", "main.myPair", "main.TypeSafeTree[main.myPair]":
+			if u := typ.Underlying(); u != typ {
+				t.Errorf("underlying type of %s is %s, want itself", typ, u)
+			}
+		}
+	}
+	if !found {
+		t.Errorf("no time.Duration type")
+	}
+}
+
+func TestLogicalFrames(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	for _, g := range p.Goroutines() {
+		for _, f := range g.Frames() {
+			lfs := f.LogicalFrames()
+			if last := lfs[len(lfs)-1]; last.Name != f.Func().Name() || last.Inlined {
+				t.Errorf("last logical frame of %s is %+v", f.Func().Name(), last)
+			}
+			if f.Func().Name() != "main.main.gowrap1" {
+				continue
+			}
+			// useLarge is small enough to be inlined into the wrapper
+			// for go useLarge(...).
+			if len(lfs) != 2 || lfs[0].Name != "main.useLarge" || !lfs[0].Inlined || filepath.Base(lfs[0].File) != "test.go" || lfs[0].Line <= 0 {
+				t.Errorf("logical frames of main.main.gowrap1 are %+v, want main.useLarge inlined", lfs)
+			}
+			return
+		}
+	}
+	t.Errorf("no goroutine running main.main.gowrap1")
+}
+
+func TestForEachReachable(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	// reachable finds what x reaches independently, depth first.
+	reachable := func(x Object) map[Object]bool {
+		seen := map[Object]bool{}
+		var walk func(Object)
+		walk = func(y Object) {
+			if seen[y] {
+				return
+			}
+			seen[y] = true
+			p.ForEachPtr(y, func(_ int64, z Object, _ int64) bool {
+				walk(z)
+				return true
+			})
+		}
+		walk(x)
+		return seen
+	}
+	// Start from the anyNode that reaches the most.
+	var x Object
+	var want map[Object]bool
+	p.ForEachObject(func(y Object) bool {
+		if typeName(p, y) == "main.anyNode" {
+			if r := reachable(y); len(r) > len(want) {
+				x, want = y, r
+			}
+		}
+		return true
+	})
+	if len(want) < 3 {
+		t.Fatalf("no main.anyNode reaching 3 or more objects")
+	}
+
+	visits := map[Object]int{}
+	first := true
+	p.ForEachReachable(x, func(y Object) bool {
+		if first && y != x {
+			t.Errorf("first visit is %x, want %x", p.Addr(y), p.Addr(x))
+		}
+		first = false
+		visits[y]++
+		return true
+	})
+	for y, n := range visits {
+		if n != 1 {
+			t.Errorf("object %x visited %d times", p.Addr(y), n)
+		}
+		if !want[y] {
+			t.Errorf("object %x visited, but isn't reachable", p.Addr(y))
+		}
+	}
+	if len(visits) != len(want) {
+		t.Errorf("visited %d objects, want %d", len(visits), len(want))
+	}
+
+	n := 0
+	p.ForEachReachable(x, func(Object) bool {
+		n++
+		return n < 2
+	})
+	if n != 2 {
+		t.Errorf("returning false after 2 visits, got %d", n)
+	}
+}
+
+func TestObjectFingerprint(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	// The typeSafeTree nodes above the leaves all have the same shape:
+	// two child nodes and an entry.
+	inner := map[uint64]int{}
+	p.ForEachObject(func(x Object) bool {
+		if typeName(p, x) != "main.typeSafeNode[main.myPair]" {
+			return true
+		}
+		n := 0
+		p.ForEachPtr(x, func(int64, Object, int64) bool {
+			n++
+			return true
+		})
+		if n == 3 {
+			inner[p.ObjectFingerprint(x)]++
+		}
+		return true
+	})
+	if len(inner) != 1 {
+		t.Errorf("inner tree nodes have %d distinct fingerprints, want 1", len(inner))
+	}
+
+	// Fingerprints don't depend on the rest of the heap.
+	sub, err := CoreSubgraph(p.Process(), func(r *Root) bool {
+		return r.Name == "main.globalTypeSafeTree"
+	})
+	if err != nil {
+		t.Fatalf("CoreSubgraph: %v", err)
+	}
+	sub.ForEachObject(func(x Object) bool {
+		y, _ := p.FindObject(sub.Addr(x))
+		if sub.ObjectFingerprint(x) != p.ObjectFingerprint(y) {
+			t.Errorf("object %x has different fingerprints in the full heap and a subgraph", sub.Addr(x))
+		}
+		return true
+	})
+}
+
+func TestMethods(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	want := map[string]Method{
+		"main.myPair":   {Name: "X", Exported: true},
+		"*main.anyNode": {Name: "count", PkgPath: "main"},
+	}
+	for _, typ := range p.Types() {
+		w, ok := want[typ.Name]
+		if !ok {
+			continue
+		}
+		for _, m := range p.Methods(typ) {
+			if m.Name != w.Name {
+				continue
+			}
+			if m.Exported != w.Exported || m.PkgPath != w.PkgPath {
+				t.Errorf("%s method %s: Exported = %v, PkgPath = %q, want %v, %q", typ, m.Name, m.Exported, m.PkgPath, w.Exported, w.PkgPath)
+			}
+			// The linker drops the type and code of methods nothing can
+			// call through reflection, so they may be missing.
+			if m.Type != nil && !strings.HasPrefix(m.Type.Name, "func()") {
+				t.Errorf("%s method %s: type %s, want func()", typ, m.Name, m.Type)
+			}
+			delete(want, typ.Name)
+		}
+	}
+	for name, w := range want {
+		t.Errorf("method %s of %s not found", w.Name, name)
+	}
+}
+
+func TestRuntimeDWARF(t *testing.T) {
+	file := generateExampleCore(t, []string{"-ldflags=-w"}, nil)
+	c, err := core.Core(file, "", "")
+	if err != nil {
+		t.Fatalf("can't load test core file: %v", err)
+	}
+	if _, err := Core(c); err == nil {
+		t.Fatalf("Core succeeded without DWARF")
+	}
+
+	// Take the runtime's DWARF from another program built by the same
+	// toolchain.
+	goTool, err := testenv.GoTool()
+	if err != nil {
+		t.Fatalf("cannot find go tool: %v", err)
+	}
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("package main\n\nfunc main() {}\n"), 0o666); err != nil {
+		t.Fatal(err)
+	}
+	cmd := exec.Command(goTool, "build", "-o", "other.exe", "main.go")
+	cmd.Dir = dir
+	if b, err := cmd.CombinedOutput(); err != nil {
+		t.Fatalf("error building other program: %v\n%s", err, b)
+	}
+	f, err := elf.Open(filepath.Join(dir, "other.exe"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	d, err := f.DWARF()
+	if err != nil {
+		t.Fatalf("other program has no DWARF: %v", err)
+	}
+	p, err := CoreWithOptions(c, Options{RuntimeDWARF: d})
+	if err != nil {
+		t.Fatalf("can't parse Go core: %v", err)
+	}
+	if len(p.Goroutines()) == 0 {
+		t.Errorf("no goroutines")
+	}
+	// Without the program's DWARF, only objects whose runtime type the
+	// heap records are typed.
+	n := 0
+	found := false
+	p.ForEachObject(func(x Object) bool {
+		n++
+		if typ, _ := p.Type(x); typ != nil && typ.Name == "main.Large" {
+			found = true
+		}
+		return true
+	})
+	if n == 0 {
+		t.Errorf("no objects")
+	}
+	if !found {
+		t.Errorf("no main.Large object typed from its runtime type")
+	}
+}
+
+func TestConservativeFrames(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	for _, g := range p.Goroutines() {
+		for _, f := range g.Frames() {
+			if f.Func().Name() == "main.spin" && !f.Conservative() {
+				t.Errorf("goroutine %d: asynchronously preempted frame of main.spin is not conservative", g.ID())
+			}
+		}
+	}
+
+	// The spinObj is only referenced from main.spin's frame, and from
+	// the registers runtime.asyncPreempt spilled.
+	var obj Object
+	p.ForEachObject(func(x Object) bool {
+		if p.Size(x) == 16 && p.Process().ReadInt64(p.Addr(x).Add(8)) == 0x5350494e {
+			obj = x
+			return false
+		}
+		return true
+	})
+	if obj == 0 {
+		t.Fatal("spinObj not found")
+	}
+	n := 0
+	p.ForEachReversePtr(obj, func(x Object, r *Root, i, j int64) bool {
+		if r == nil {
+			t.Errorf("spinObj referenced from object %x", p.Addr(x))
+			return true
+		}
+		n++
+		if !r.Conservative() {
+			t.Errorf("spinObj referenced from root %s, which is not conservative", r.Name)
+		}
+		return true
+	})
+	if n == 0 {
+		t.Errorf("no roots reference spinObj")
+	}
+}
+
+func TestFlagConservativeFrames(t *testing.T) {
+	c, err := core.Core(generateExampleCore(t, nil, nil), "", "")
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	p, err := CoreWithOptions(c, Options{Flags: FlagConservativeFrames})
+	if err != nil {
+		t.Fatalf("can't parse Go core: %s", err)
+	}
+	// All the Go code has stack maps, so only asynchronously
+	// preempted frames should be scanned conservatively.
+	for _, g := range p.Goroutines() {
+		for i, f := range g.Frames() {
+			preempted := f.Func().Name() == "runtime.asyncPreempt" ||
+				i > 0 && g.Frames()[i-1].Func().Name() == "runtime.asyncPreempt"
+			if f.Conservative() != preempted {
+				t.Errorf("goroutine %d: %s: Conservative() = %v, want %v", g.ID(), f.Func().Name(), f.Conservative(), preempted)
+			}
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	c, err := core.Core(generateExampleCore(t, nil, nil), "", "")
+	if err != nil {
+		t.Fatalf("can't load test core file: %s", err)
+	}
+	var phases []string
+	last := map[string][2]int64{}
+	progress := func(phase string, done, total int64) {
+		if len(phases) == 0 || phases[len(phases)-1] != phase {
+			phases = append(phases, phase)
+		}
+		if done < 0 || done > total {
+			t.Errorf("%s: done %d of %d", phase, done, total)
+		}
+		if prev, ok := last[phase]; ok && (done < prev[0] || total != prev[1]) {
+			t.Errorf("%s: done %d of %d after %d of %d", phase, done, total, prev[0], prev[1])
+		}
+		last[phase] = [2]int64{done, total}
+	}
+	if _, err := CoreWithOptions(c, Options{Progress: progress}); err != nil {
+		t.Fatalf("can't parse Go core: %s", err)
+	}
+	if want := []string{"dwarf", "spans", "goroutines", "objects"}; !slices.Equal(phases, want) {
+		t.Errorf("got phases %q, want %q", phases, want)
+	}
+	for phase, l := range last {
+		if l[0] != l[1] {
+			t.Errorf("%s: last reported %d of %d", phase, l[0], l[1])
+		}
+	}
+}
+
+func TestRegisterRoots(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	var x Object
+	p.ForEachObject(func(y Object) bool {
+		x = y
+		return false
+	})
+	// A pointer variable held in a register. It is only a pointer if
+	// the frame's registers are known.
+	for _, known := range []bool{true, false} {
+		f := &Frame{Live: map[core.Address]bool{}, regs: known}
+		r := p.makeCompositeRoot("v", p.rtTypeByName["unsafe.Pointer"], f, []rootPiece{{size: 8, kind: regPiece, value: uint64(p.Addr(x))}})
+		var got []core.Address
+		p.forEachRootPtr(r, func(_ int64, a core.Address) bool {
+			got = append(got, a)
+			return true
+		})
+		var want []core.Address
+		if known {
+			want = []core.Address{p.Addr(x)}
+		}
+		if !slices.Equal(got, want) {
+			t.Errorf("with registers known=%t, register root points to %x, want %x", known, got, want)
+		}
+	}
+}
+
+func TestMaps(t *testing.T) {
+	p := loadExampleGenerated(t, nil, nil)
+	counts := map[string]int{}
+	p.ForEachObject(func(x Object) bool {
+		if typ, _ := p.Type(x); typ != nil {
+			counts[typ.Name]++
+		}
+		return true
+	})
+	// globalBigMap's groups point to its keys and elems, and the elems
+	// to leaves, as do globalSmallMap's.
+	for name, want := range map[string]int{
+		"main.mapKey":  100,
+		"main.mapVal":  100,
+		"main.mapLeaf": 103,
+	} {
+		if got := counts[name]; got != want {
+			t.Errorf("got %d objects of type %s, want %d", got, name, want)
+		}
+	}
+}
@@ -5,20 +5,61 @@
 package gocore
 
 import (
+	"fmt"
+	"sync"
+
 	"golang.org/x/debug/internal/core"
 )
 
 type Goroutine struct {
-	r         region // inferior region holding the runtime.g
-	stackSize int64  // current stack allocation
-	frames    []*Frame
+	r       region       // inferior region holding the runtime.g
+	stackLo core.Address // current stack allocation is [stackLo, stackHi)
+	stackHi core.Address
+	frames  []*Frame
+
+	status     string // see Status
+	waitReason string // see WaitReason
+
+	ancestors [][]*Frame   // creation stacks of ancestors; see Ancestors
+	unwindErr *UnwindError // why frames is incomplete, if it is
 
 	// TODO: defers, in-progress panics
 }
 
 // Stack returns the total allocated stack for g.
 func (g *Goroutine) Stack() int64 {
-	return g.stackSize
+	return g.stackHi.Sub(g.stackLo)
+}
+
+// StackInUse returns how much of g's stack is in use: the distance from
+// the top of the stack to the lowest stack pointer of g's frames on it.
+// Frames on other stacks, such as a signal handler's, don't count.
+// It returns 0 if none of g's frames are on its stack.
+func (g *Goroutine) StackInUse() int64 {
+	sp := g.stackHi
+	for _, f := range g.frames {
+		if f.min >= g.stackLo && f.min < sp {
+			sp = f.min
+		}
+	}
+	return g.stackHi.Sub(sp)
+}
+
+// ID returns the goroutine ID (goid) of g.
+func (g *Goroutine) ID() uint64 {
+	return g.r.Field("goid").Uint64()
+}
+
+// Status returns the scheduling status of g, as printed in runtime
+// tracebacks: "runnable", "running", "syscall", "waiting", and so on.
+func (g *Goroutine) Status() string {
+	return g.status
+}
+
+// WaitReason returns why g is waiting, such as "chan receive",
+// or "" if g is not waiting.
+func (g *Goroutine) WaitReason() string {
+	return g.waitReason
 }
 
 // Addr returns the address of the runtime.g that identifies this goroutine.
@@ -34,6 +75,39 @@ func (g *Goroutine) Frames() []*Frame {
 	return g.frames
 }
 
+// UnwindError returns the reason the stack of g could not be unwound
+// all the way to its outermost frame, or nil if it could. If it is
+// non-nil, Frames holds only the frames read before the failure.
+func (g *Goroutine) UnwindError() *UnwindError {
+	return g.unwindErr
+}
+
+// An UnwindError describes where and why unwinding a goroutine's stack
+// stopped early. This usually means that the stack is corrupted.
+type UnwindError struct {
+	SP, PC core.Address // stack pointer and pc of the frame that couldn't be read
+	Frames int          // number of frames read before the failure
+	Err    error
+}
+
+func (e *UnwindError) Error() string {
+	return fmt.Sprintf("unwinding stopped after %d frames at sp=%#x pc=%#x: %v", e.Frames, e.SP, e.PC, e.Err)
+}
+
+// Ancestors returns the stacks of the goroutines that created g, as
+// recorded when they executed the go statement. The first entry is the
+// stack of g's creator, the next one that of the creator's creator,
+// and so on. Within each stack the first frame is the most recent one.
+//
+// The runtime only records ancestors when the program is run with
+// GODEBUG=tracebackancestors=N, and then at most N of them; otherwise
+// Ancestors returns nil. Ancestor frames are reconstructed from PCs
+// alone, so only Func, PC, Parent and LogicalFrames are meaningful. They have no
+// extent, live pointers or roots.
+func (g *Goroutine) Ancestors() [][]*Frame {
+	return g.ancestors
+}
+
 // A Frame represents the local variables of a single Go function invocation.
 // (Note that in the presence of inlining, a Frame may contain local variables
 // for more than one Go function invocation.)
@@ -41,6 +115,7 @@ type Frame struct {
 	parent   *Frame
 	f        *Func        // function whose activation record this frame is
 	pc       core.Address // resumption point
+	posPC    core.Address // pc for looking up positions: pc, or pc-1 if pc is a return address
 	min, max core.Address // extent of stack frame
 
 	// Set of locations that contain a live pointer. Note that this set
@@ -48,7 +123,17 @@ type Frame struct {
 	// for the frame).
 	Live map[core.Address]bool
 
-	roots []*Root // GC roots in this frame
+	conservative bool // Live is a guess; see Conservative
+
+	// regs reports whether the register values used to locate the
+	// frame's variables are the frame's own: the thread's registers
+	// for the innermost frame of a running goroutine, or those saved
+	// by a signal for the frame it interrupted. Otherwise, variables
+	// in registers are dead, since Go saves no registers across calls.
+	regs bool
+
+	roots  []*Root    // GC roots in this frame
+	locals []LocalVar // named variables in this frame, from DWARF
 
 	// TODO: keep vars from dwarf around?
 }
@@ -73,11 +158,57 @@ func (f *Frame) PC() core.Address {
 	return f.pc
 }
 
+// Conservative reports whether the frame is scanned conservatively,
+// as the runtime does for a function asynchronously preempted at a
+// point with no stack maps, or, with FlagConservativeFrames, because
+// its stack maps are missing. Live then holds every word of the frame
+// that points into the heap, whether or not the function will use it again.
+func (f *Frame) Conservative() bool {
+	return f.conservative
+}
+
+// LogicalFrames returns the calls that f is executing, innermost first.
+// The compiler may have inlined calls into f's function. Those come
+// first, followed by f's function itself, so there is always at least
+// one. Each position is the one reached in that function: the current
+// instruction for the innermost frame of a goroutine, and the call site
+// otherwise.
+func (f *Frame) LogicalFrames() []LogicalFrame {
+	return f.f.module.logicalFrames(f.f, f.posPC.Sub(f.f.entry))
+}
+
+// A LogicalFrame is a call in a stack frame. Unlike a Frame, it may
+// have been inlined into the function the frame belongs to.
+type LogicalFrame struct {
+	Name    string // name of the function called
+	File    string // position in that function, or "?", 0 if unknown
+	Line    int64
+	Inlined bool // whether the call was inlined
+}
+
 // Roots returns a list of all the garbage collection roots in the frame.
 func (f *Frame) Roots() []*Root {
 	return f.roots
 }
 
+// Locals returns the local variables and arguments of f that are
+// described by DWARF and live at f's PC, in DWARF order.
+func (f *Frame) Locals() []LocalVar {
+	return f.locals
+}
+
+// A LocalVar is a named local variable or argument of a stack frame.
+type LocalVar struct {
+	Name string
+	Type *Type
+	// Addr is the address of the variable, or 0 if it is not stored
+	// in contiguous memory. Such variables are split into pieces, some
+	// of which may be in registers; use Process.ReadRoot to read them.
+	Addr core.Address
+	// Root is the GC root for the variable.
+	Root *Root
+}
+
 // Parent returns the parent frame of f, or nil if it is the top of the stack.
 func (f *Frame) Parent() *Frame {
 	return f.parent
@@ -86,7 +217,7 @@ func (f *Frame) Parent() *Frame {
 // A Func represents a Go function.
 type Func struct {
 	r              region // inferior region holding a runtime._func
-	module         *module
+	module         *Module
 	name           string
 	entry          core.Address
 	frameSize      pcTab // map from pc to frame size at that pc
@@ -95,6 +226,12 @@ type Func struct {
 	stackMap       pcTab // map from pc to stack map # (index into locals and args bitmaps)
 	methodValueFor *Func // if != nil, this Func is a method value wrapper, and *Func is the method
 	closure        *Type // the type to use for closures of this function. Lazily allocated.
+
+	// Maps from pc to file number, line number and inline tree index. Lazily read.
+	readLines           sync.Once
+	pcfile, pcln, pcinl pcTab
+	pcinlOff            int64        // offset of pcinl in the module's pctab, -1 if none
+	inlTree             core.Address // the runtime.inlinedCall array, if pcinlOff >= 0
 }
 
 // Name returns the name of the function, as reported by DWARF.
@@ -107,3 +244,11 @@ func (f *Func) Name() string {
 func (f *Func) Entry() core.Address {
 	return f.entry
 }
+
+// FileLine returns the source file and line number of the instruction
+// at pc, which must be in f. It returns "?", 0 if they are unknown.
+// For frames other than the innermost one, Frame.PC is a return address;
+// pass Frame.PC()-1 to get the line of the call.
+func (f *Func) FileLine(pc core.Address) (file string, line int64) {
+	return f.module.fileLine(f, pc.Sub(f.entry))
+}
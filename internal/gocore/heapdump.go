@@ -0,0 +1,285 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"strings"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// Record tags and field kinds of the heap dump format.
+// See runtime/heapdump.go and https://golang.org/s/go15heapdump.
+const (
+	dumpEOF         = 0
+	dumpObject      = 1
+	dumpGoroutine   = 4
+	dumpStackFrame  = 5
+	dumpParams      = 6
+	dumpFinalizer   = 7
+	dumpOSThread    = 9
+	dumpMemStats    = 10
+	dumpData        = 12
+	dumpBSS         = 13
+	dumpFieldEOL    = 0
+	dumpFieldPtr    = 1
+	dumpHeader      = "go1.7 heap dump\n"
+	dumpMemStatsLen = 24 // number of MemStats fields before PauseNs
+)
+
+// heapDumpWriter writes the primitives of the heap dump format.
+// Write errors are sticky in the bufio.Writer and reported by Flush.
+type heapDumpWriter struct {
+	*bufio.Writer
+	buf [binary.MaxVarintLen64]byte
+}
+
+func (w *heapDumpWriter) int(v uint64) {
+	w.Write(w.buf[:binary.PutUvarint(w.buf[:], v)])
+}
+
+func (w *heapDumpWriter) bool(b bool) {
+	if b {
+		w.int(1)
+	} else {
+		w.int(0)
+	}
+}
+
+func (w *heapDumpWriter) bytes(b []byte) {
+	w.int(uint64(len(b)))
+	w.Write(b)
+}
+
+func (w *heapDumpWriter) string(s string) {
+	w.int(uint64(len(s)))
+	w.WriteString(s)
+}
+
+// fields writes a field list with a pointer at each of offs.
+func (w *heapDumpWriter) fields(offs []int64) {
+	for _, off := range offs {
+		w.int(dumpFieldPtr)
+		w.int(uint64(off))
+	}
+	w.int(dumpFieldEOL)
+}
+
+// WriteHeapDump writes p to w in the format of runtime/debug.WriteHeapDump,
+// so that tools built for that format can read it.
+//
+// The dump has the live heap objects, goroutines and their stack
+// frames, OS threads, data and bss segments, finalizers, and the
+// memory statistics gocore knows about. Unlike a dump written by the
+// runtime, it has no garbage objects, and no type, itab, defer, panic
+// or memory profile records. Pointer fields of stack frames are only
+// those gocore found to be live.
+func (p *Process) WriteHeapDump(w io.Writer) error {
+	d := &heapDumpWriter{Writer: bufio.NewWriter(w)}
+	ptrSize := p.proc.PtrSize()
+
+	d.WriteString(dumpHeader)
+
+	d.int(dumpParams)
+	d.bool(p.proc.ByteOrder() == binary.BigEndian)
+	d.int(uint64(ptrSize))
+	var arenaStart, arenaEnd core.Address
+	if len(p.spans) > 0 {
+		arenaStart = p.spans[0].Base
+		last := p.spans[len(p.spans)-1]
+		arenaEnd = last.Base.Add(last.Size)
+	}
+	d.int(uint64(arenaStart))
+	d.int(uint64(arenaEnd))
+	d.string(p.proc.Arch())
+	d.string(p.buildVersion)
+	var ncpu int32
+	for _, name := range []string{"numCPUStartup", "ncpu"} {
+		if r, ok := p.rtGlobals[name]; ok {
+			ncpu = r.Int32()
+			break
+		}
+	}
+	d.int(uint64(ncpu))
+
+	var offs []int64
+	p.ForEachObject(func(x Object) bool {
+		a := core.Address(x)
+		b, _ := p.ReadObject(x)
+		offs = offs[:0]
+		for off := int64(0); off < int64(len(b)); off += ptrSize {
+			if p.isPtrFromHeap(a.Add(off)) {
+				offs = append(offs, off)
+			}
+		}
+		d.int(dumpObject)
+		d.int(uint64(a))
+		d.bytes(b)
+		d.fields(offs)
+		return true
+	})
+
+	for _, g := range p.goroutines {
+		p.dumpGoroutine(d, g)
+	}
+
+	if allm, ok := p.rtGlobals["allm"]; ok {
+		for mp := allm; mp.Address() != 0; mp = mp.Deref().Field("alllink") {
+			m := mp.Deref()
+			d.int(dumpOSThread)
+			d.int(uint64(m.a))
+			d.int(p.proc.ReadUint64(m.Field("id").a))
+			d.int(m.Field("procid").Uint64())
+		}
+	}
+
+	for _, m := range p.modules {
+		for _, seg := range [...]struct {
+			tag  uint64
+			name string
+		}{{dumpData, "data"}, {dumpBSS, "bss"}} {
+			min := core.Address(m.r.Field(seg.name).Uintptr())
+			max := core.Address(m.r.Field("e" + seg.name).Uintptr())
+			b, _ := p.readMem(min, max.Sub(min))
+			offs = offs[:0]
+			for off := int64(0); off < int64(len(b)); off += ptrSize {
+				if p.IsPtr(min.Add(off)) {
+					offs = append(offs, off)
+				}
+			}
+			d.int(seg.tag)
+			d.int(uint64(min))
+			d.bytes(b)
+			d.fields(offs)
+		}
+	}
+
+	if typ := p.rtTypeByName["runtime.specialfinalizer"]; typ != nil {
+		for _, s := range p.specials {
+			if s.Kind != "Finalizer" {
+				continue
+			}
+			r := region{p: p.proc, a: s.Addr, typ: typ}
+			fn := r.Field("fn").Address()
+			var pc core.Address
+			if fn != 0 {
+				pc = p.proc.ReadPtr(fn)
+			}
+			d.int(dumpFinalizer)
+			d.int(uint64(s.Obj))
+			d.int(uint64(fn))
+			d.int(uint64(pc))
+			d.int(uint64(r.Field("fint").Address()))
+			d.int(uint64(r.Field("ot").Address()))
+		}
+	}
+
+	p.dumpMemStats(d)
+
+	d.int(dumpEOF)
+	return d.Flush()
+}
+
+// dumpGoroutine writes the records for g and its stack frames.
+func (p *Process) dumpGoroutine(d *heapDumpWriter, g *Goroutine) {
+	var sp core.Address
+	if len(g.frames) > 0 {
+		sp = g.frames[0].min
+	}
+	// Like the runtime, report goroutines started by the runtime,
+	// other than the main goroutine, as system goroutines.
+	var system bool
+	if f := p.funcTab.find(core.Address(g.r.Field("startpc").Uintptr())); f != nil {
+		system = strings.HasPrefix(f.name, "runtime.") && f.name != "runtime.main"
+	}
+	status := g.r.Field("atomicstatus").Field("value").Uint32()
+	d.int(dumpGoroutine)
+	d.int(uint64(g.r.a))
+	d.int(uint64(sp))
+	d.int(g.ID())
+	d.int(g.r.Field("gopc").Uintptr())
+	d.int(uint64(status &^ uint32(p.rtConsts.get("runtime._Gscan"))))
+	d.bool(system)
+	d.bool(false) // isbackground, no longer used
+	d.int(p.proc.ReadUint64(g.r.Field("waitsince").a))
+	d.string(g.waitReason)
+	d.int(uint64(g.r.Field("sched").Field("ctxt").Address()))
+	d.int(uint64(g.r.Field("m").Address()))
+	d.int(uint64(g.r.Field("_defer").Address()))
+	d.int(uint64(g.r.Field("_panic").Address()))
+
+	var offs []int64
+	for depth, f := range g.frames {
+		var child core.Address
+		if depth > 0 {
+			child = g.frames[depth-1].min
+		}
+		offs = offs[:0]
+		for _, r := range f.roots {
+			if !r.HasAddress() {
+				continue // in registers
+			}
+			base := r.Addr().Sub(f.min)
+			p.forEachRootPtr(r, func(off int64, _ core.Address) bool {
+				offs = append(offs, base+off)
+				return true
+			})
+		}
+		b, _ := p.readMem(f.min, f.max.Sub(f.min))
+		d.int(dumpStackFrame)
+		d.int(uint64(f.min))
+		d.int(uint64(depth))
+		d.int(uint64(child))
+		d.bytes(b)
+		d.int(uint64(f.f.entry))
+		d.int(uint64(f.pc))
+		d.int(uint64(f.pc)) // continuation pc
+		d.string(f.f.name)
+		d.fields(offs)
+	}
+}
+
+// dumpMemStats writes a runtime.MemStats record. Fields gocore can't
+// compute are zero.
+func (p *Process) dumpMemStats(d *heapDumpWriter) {
+	stat := func(chain ...string) uint64 {
+		if s := p.stats.Sub(chain...); s != nil {
+			return uint64(s.Value)
+		}
+		return 0
+	}
+	var ms [dumpMemStatsLen]uint64
+	ms[0] = stat("heap", "in use spans", "alloc")   // Alloc
+	ms[2] = stat()                                  // Sys
+	ms[6] = ms[0]                                   // HeapAlloc
+	ms[7] = stat("heap")                            // HeapSys
+	ms[8] = stat("heap", "free spans")              // HeapIdle
+	ms[9] = stat("heap", "in use spans")            // HeapInuse
+	ms[10] = stat("heap", "free spans", "released") // HeapReleased
+	ms[11] = uint64(p.nObj)                         // HeapObjects
+	ms[12] = stat("heap", "manual spans", "alloc")  // StackInuse
+	var pauses [256]uint64
+	var numGC uint32
+	if m, ok := p.rtGlobals["memstats"]; ok {
+		ms[22] = m.Field("last_gc_unix").Uint64()   // LastGC
+		ms[23] = m.Field("pause_total_ns").Uint64() // PauseTotalNs
+		pa := m.Field("pause_ns")
+		for i := range pauses {
+			pauses[i] = pa.ArrayIndex(int64(i)).Uint64()
+		}
+		numGC = m.Field("numgc").Uint32()
+	}
+	d.int(dumpMemStats)
+	for _, v := range ms {
+		d.int(v)
+	}
+	for _, v := range pauses {
+		d.int(v)
+	}
+	d.int(uint64(numGC))
+}
@@ -0,0 +1,161 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"golang.org/x/debug/internal/core"
+)
+
+// A Method is a method in the method set of a type.
+type Method struct {
+	Name     string
+	PkgPath  string // package of an unexported method; "" for exported ones
+	Exported bool
+	Type     *Type // method type, without the receiver; nil if the linker dropped it
+	Func     *Func // code an interface call runs; nil if the linker dropped it
+}
+
+// Methods returns the method set of t, read from t's runtime type
+// descriptor, in the order the runtime keeps it (sorted by name).
+// It returns nil if t has no methods, or no runtime type descriptor.
+// The method set of a pointer type *T is in the descriptor of *T, not T.
+func (p *Process) Methods(t *Type) []Method {
+	a := t.goAddr
+	if a == 0 {
+		p.rtTypeMu.Lock()
+		for x, u := range p.rtTypeMap {
+			if u == t {
+				a = x
+				break
+			}
+		}
+		p.rtTypeMu.Unlock()
+	}
+	if a == 0 {
+		return nil
+	}
+	m := p.findModule(a)
+	if m == nil {
+		return nil // reflect-generated type
+	}
+	r := p.findRuntimeType(a)
+	if r.TFlag()&uint8(p.rtConsts.get("internal/abi.TFlagUncommon")) == 0 {
+		return nil
+	}
+	ut := p.rtTypeByName["internal/abi.UncommonType"]
+	mt := p.rtTypeByName["internal/abi.Method"]
+	kt := p.kindType(r)
+	if ut == nil || mt == nil || kt == nil {
+		return nil
+	}
+
+	// The uncommon data follows the kind-specific type descriptor.
+	u := region{p: p.proc, a: a.Add(kt.Size), typ: ut}
+	var pkgPath string
+	if off := u.Field("PkgPath").Int32(); off != 0 {
+		pkgPath = p.readName(m.types.Add(int64(off))).name
+	}
+	n := int64(u.Field("Mcount").Uint16())
+	ms := u.a.Add(int64(u.Field("Moff").Uint32()))
+	text := core.Address(m.r.Field("text").Uintptr())
+	var methods []Method
+	for i := int64(0); i < n; i++ {
+		x := region{p: p.proc, a: ms.Add(i * mt.Size), typ: mt}
+		name := p.readName(m.types.Add(int64(x.Field("Name").Int32())))
+		meth := Method{Name: name.name, Exported: name.exported}
+		if !meth.Exported {
+			meth.PkgPath = pkgPath
+			if name.pkgPath != "" {
+				meth.PkgPath = name.pkgPath // promoted from another package
+			}
+		}
+		// The linker sets the offsets of methods it found unreachable to -1.
+		if off := x.Field("Mtyp").Int32(); off != -1 {
+			meth.Type = p.runtimeType2Type(m.types.Add(int64(off)), 0)
+		}
+		// TODO: binaries with more than one text section (see
+		// runtime.moduledata.textOff) need textsectmap to resolve this.
+		if off := x.Field("Ifn").Int32(); off != -1 {
+			meth.Func = p.funcTab.find(text.Add(int64(uint32(off))))
+		}
+		methods = append(methods, meth)
+	}
+	return methods
+}
+
+// findModule returns the module whose type data holds the runtime type
+// at a, or nil if there is none.
+func (p *Process) findModule(a core.Address) *Module {
+	for _, m := range p.modules {
+		if m.types <= a && a < m.etypes {
+			return m
+		}
+	}
+	return nil
+}
+
+// kindType returns the kind-specific type descriptor type
+// (abi.StructType, abi.PtrType, ...) for the runtime type r, or nil if
+// it isn't in the DWARF.
+func (p *Process) kindType(r runtimeType) *Type {
+	kind := int64(r.Kind_())
+	if mask, ok := p.rtConsts.find("internal/abi.KindMask"); ok {
+		kind &= mask // Go 1.25 and earlier.
+	}
+	names := map[string][]string{
+		"Array":     {"ArrayType"},
+		"Chan":      {"ChanType"},
+		"Func":      {"FuncType"},
+		"Interface": {"InterfaceType"},
+		"Map":       {"MapType", "SwissMapType", "OldMapType"},
+		"Pointer":   {"PtrType"},
+		"Slice":     {"SliceType"},
+		"Struct":    {"StructType"},
+	}
+	for k, ts := range names {
+		if v, ok := p.rtConsts.find("internal/abi." + k); !ok || v != kind {
+			continue
+		}
+		for _, t := range ts {
+			if typ := p.rtTypeByName["internal/abi."+t]; typ != nil {
+				return typ
+			}
+		}
+		return nil
+	}
+	return p.rtTypeByName["internal/abi.Type"]
+}
+
+// A runtimeName is a decoded abi.Name.
+type runtimeName struct {
+	name     string
+	exported bool
+	pkgPath  string // set only if the name records its own package
+}
+
+// readName decodes the abi.Name at a. See internal/abi/type.go.
+func (p *Process) readName(a core.Address) runtimeName {
+	const (
+		flagExported = 1 << 0
+		flagHasTag   = 1 << 1
+		flagPkgPath  = 1 << 2
+	)
+	flags := p.proc.ReadUint8(a)
+	i, n := readNameLen(p, a)
+	b := make([]byte, n)
+	p.proc.ReadAt(b, a.Add(i+1))
+	x := runtimeName{name: string(b), exported: flags&flagExported != 0}
+	if flags&flagPkgPath != 0 {
+		off := a.Add(i + 1 + n)
+		if flags&flagHasTag != 0 {
+			j, k := readNameLen(p, off.Add(-1))
+			off = off.Add(j + k)
+		}
+		if m := p.findModule(a); m != nil && p.proc.ReadInt32(off) != 0 {
+			x.pkgPath = p.readName(m.types.Add(int64(p.proc.ReadInt32(off)))).name
+		}
+	}
+	return x
+}
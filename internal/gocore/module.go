@@ -12,15 +12,26 @@ import (
 	"golang.org/x/debug/internal/core"
 )
 
-type module struct {
+// A Module is a unit of Go code loaded into the inferior: its executable,
+// or a plugin or shared library built from Go.
+type Module struct {
 	r             region       // inferior region holding a runtime.moduledata
 	types, etypes core.Address // range that holds all the runtime._type data in this module
+	funcs         []*Func      // in PC order
+
+	// Tables for mapping pcs to file and line numbers (Go 1.16+).
+	// pctab.a == 0 if they aren't available.
+	pctab, cutab, filetab region
+
+	// For expanding inlined calls.
+	funcnametab region
+	inlinedCall *Type // runtime.inlinedCall, nil if unknown
 }
 
-func readModules(rtTypeByName map[string]*Type, rtConsts map[string]int64, rtGlobals map[string]region) ([]*module, *funcTab, error) {
+func readModules(rtTypeByName map[string]*Type, rtConsts map[string]int64, rtGlobals map[string]region) ([]*Module, *funcTab, error) {
 	ms := rtGlobals["modulesSlice"].Deref()
 	n := ms.SliceLen()
-	var modules []*module
+	var modules []*Module
 	var fnTab funcTab
 	fnTab.byName = make(map[string]*Func)
 	for i := int64(0); i < n; i++ {
@@ -31,8 +42,8 @@ func readModules(rtTypeByName map[string]*Type, rtConsts map[string]int64, rtGlo
 	return modules, &fnTab, nil
 }
 
-func readModule(r region, fns *funcTab, rtTypeByName map[string]*Type, rtConsts map[string]int64) *module {
-	m := &module{r: r}
+func readModule(r region, fns *funcTab, rtTypeByName map[string]*Type, rtConsts map[string]int64) *Module {
+	m := &Module{r: r}
 	m.types = core.Address(r.Field("types").Uintptr())
 	m.etypes = core.Address(r.Field("etypes").Uintptr())
 
@@ -44,6 +55,13 @@ func readModule(r region, fns *funcTab, rtTypeByName map[string]*Type, rtConsts
 		// In 1.16, pclntable was split up into pctab and funcnametab.
 		pctab = r.Field("pctab")
 		funcnametab = r.Field("funcnametab")
+		if r.HasField("cutab") && r.HasField("filetab") {
+			m.pctab = pctab
+			m.cutab = r.Field("cutab")
+			m.filetab = r.Field("filetab")
+			m.funcnametab = funcnametab
+			m.inlinedCall = rtTypeByName["runtime.inlinedCall"]
+		}
 	}
 	ftab := r.Field("ftab")
 	n := ftab.SliceLen() - 1 // last slot is a dummy, just holds entry
@@ -63,19 +81,25 @@ func readModule(r region, fns *funcTab, rtTypeByName map[string]*Type, rtConsts
 			panic(fmt.Errorf("entry %x and min %x don't match for %s", f.entry, min, f.name))
 		}
 		fns.add(min, max, f)
+		m.funcs = append(m.funcs, f)
 	}
 
 	return m
 }
 
+// Funcs returns the functions in m, in the order of their entry PCs.
+func (m *Module) Funcs() []*Func {
+	return m.funcs
+}
+
 // readFunc parses a runtime._func and returns a *Func.
 // r must have type runtime._func.
 // pcln must have type []byte and represent the module's pcln table region.
-func (m *module) readFunc(r region, pctab region, funcnametab region, rtConsts constsMap) *Func {
+func (m *Module) readFunc(r region, pctab region, funcnametab region, rtConsts constsMap) *Func {
 	f := &Func{module: m, r: r}
 	f.entry = m.textAddr(r.Field("entryOff").Uint32())
 	nameOff := r.Field("nameOff").Int32()
-	f.name = r.p.ReadCString(funcnametab.SliceIndex(int64(nameOff)).a)
+	f.name = r.p.ReadCString(funcnametab.SliceIndex(int64(nameOff)).a, funcnametab.SliceLen()-int64(nameOff))
 	pcspIdx := int64(r.Field("pcsp").Uint32())
 	f.frameSize.read(r.p, pctab.SliceIndex(pcspIdx).a)
 
@@ -103,6 +127,15 @@ func (m *module) readFunc(r region, pctab region, funcnametab region, rtConsts c
 		a = a.Add(4)
 	}
 
+	// Note where to find inlined calls. They're read along with the line tables.
+	f.pcinlOff = -1
+	inlIdx, ok1 := rtConsts.find("internal/abi.PCDATA_InlTreeIndex")
+	inlTree, ok2 := rtConsts.find("internal/abi.FUNCDATA_InlTree")
+	if ok1 && ok2 && int(inlIdx) < len(f.pcdata) && int(inlTree) < len(f.funcdata) && f.funcdata[inlTree] != 0 {
+		f.pcinlOff = int64(f.pcdata[inlIdx])
+		f.inlTree = f.funcdata[inlTree]
+	}
+
 	// Read pcln tables we need.
 	if stackmap := int(rtConsts.get("internal/abi.PCDATA_StackMapIndex")); stackmap < len(f.pcdata) {
 		f.stackMap.read(r.p, pctab.SliceIndex(int64(f.pcdata[stackmap])).a)
@@ -113,10 +146,79 @@ func (m *module) readFunc(r region, pctab region, funcnametab region, rtConsts c
 	return f
 }
 
+// fileLine returns the file and line number of offset off in f.
+// See runtime.funcline1.
+func (m *Module) fileLine(f *Func, off int64) (string, int64) {
+	if m.pctab.a == 0 {
+		return "?", 0
+	}
+	f.readLines.Do(func() { m.readLines(f) })
+	fileno, err := f.pcfile.find(off)
+	if err != nil || fileno < 0 {
+		return "?", 0
+	}
+	line, err := f.pcln.find(off)
+	if err != nil || line < 0 {
+		return "?", 0
+	}
+	i := int64(f.r.Field("cuOffset").Uint32()) + fileno
+	if i >= m.cutab.SliceLen() {
+		return "?", 0
+	}
+	fileoff := m.cutab.SliceIndex(i).Uint32()
+	if fileoff == ^uint32(0) || int64(fileoff) >= m.filetab.SliceLen() {
+		return "?", line
+	}
+	return f.r.p.ReadCString(m.filetab.SliceIndex(int64(fileoff)).a, m.filetab.SliceLen()-int64(fileoff)), line
+}
+
+// readLines reads f's tables mapping pcs to files, lines, and inlined calls.
+func (m *Module) readLines(f *Func) {
+	f.pcfile.read(f.r.p, m.pctab.SliceIndex(int64(f.r.Field("pcfile").Uint32())).a)
+	f.pcln.read(f.r.p, m.pctab.SliceIndex(int64(f.r.Field("pcln").Uint32())).a)
+	if f.pcinlOff >= 0 && m.inlinedCall != nil {
+		f.pcinl.read(f.r.p, m.pctab.SliceIndex(f.pcinlOff).a)
+	} else {
+		f.pcinl.setEmpty()
+	}
+}
+
+// maxInlineDepth limits how many inlined calls logicalFrames will expand,
+// in case the inline tree is corrupt and has a cycle.
+const maxInlineDepth = 1000
+
+// logicalFrames returns the calls at offset off in f, starting with the
+// innermost inlined call and ending with f itself.
+// See runtime.inlineUnwinder.
+func (m *Module) logicalFrames(f *Func, off int64) []LogicalFrame {
+	var frames []LogicalFrame
+	if m.pctab.a != 0 {
+		f.readLines.Do(func() { m.readLines(f) })
+		for len(frames) < maxInlineDepth {
+			idx, err := f.pcinl.find(off)
+			if err != nil || idx < 0 {
+				break
+			}
+			call := region{p: f.r.p, a: f.inlTree.Add(idx * m.inlinedCall.Size), typ: m.inlinedCall}
+			nameOff := int64(call.Field("nameOff").Int32())
+			name := "?"
+			if nameOff >= 0 && nameOff < m.funcnametab.SliceLen() {
+				name = f.r.p.ReadCString(m.funcnametab.SliceIndex(nameOff).a, m.funcnametab.SliceLen()-nameOff)
+			}
+			file, line := m.fileLine(f, off)
+			frames = append(frames, LogicalFrame{Name: name, File: file, Line: line, Inlined: true})
+			// Continue at the call site in the caller.
+			off = int64(call.Field("parentPc").Int32())
+		}
+	}
+	file, line := m.fileLine(f, off)
+	return append(frames, LogicalFrame{Name: f.name, File: file, Line: line})
+}
+
 // textAddr returns the address of a text offset.
 //
 // Equivalent to runtime.moduledata.textAddr.
-func (m *module) textAddr(off32 uint32) core.Address {
+func (m *Module) textAddr(off32 uint32) core.Address {
 	off := uint64(off32)
 	res := m.r.Field("text").Uintptr() + off
 
@@ -0,0 +1,78 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"golang.org/x/debug/internal/core"
+)
+
+// An AllocRecord is an entry of the runtime's heap profile: the
+// allocations of one size made at one call stack, as sampled by the
+// runtime (see runtime.MemProfileRate). It corresponds to a
+// runtime.MemProfileRecord.
+type AllocRecord struct {
+	// Stack holds the return PCs of the allocating call stack,
+	// innermost first, like runtime.MemProfileRecord.Stack0.
+	Stack []core.Address
+
+	Size   int64 // size of each allocation
+	Allocs int64 // number of sampled allocations
+	Frees  int64 // number of those the GC has freed
+}
+
+// InUseObjects returns the number of the sampled allocations that are
+// still in use.
+func (r *AllocRecord) InUseObjects() int64 {
+	return r.Allocs - r.Frees
+}
+
+// InUseBytes returns the size of the sampled allocations that are
+// still in use.
+func (r *AllocRecord) InUseBytes() int64 {
+	return r.InUseObjects() * r.Size
+}
+
+// AllocProfile returns the runtime's heap profile, or nil if the
+// inferior wasn't profiling memory. The linker turns profiling off in
+// binaries that can't call runtime.MemProfile (and so can't write a
+// heap profile); others sample an allocation every 512 KB by default.
+//
+// The runtime publishes its profile as of the last GC but one, so
+// that sweeping has accounted for every free. AllocProfile instead
+// includes all the allocations and frees recorded so far, since that
+// is closer to what the core holds.
+func (p *Process) AllocProfile() []AllocRecord {
+	mb, ok := p.rtGlobals["mbuckets"]
+	bucketType := p.rtTypeByName["runtime.bucket"]
+	memRecordType := p.rtTypeByName["runtime.memRecord"]
+	if !ok || bucketType == nil || memRecordType == nil {
+		return nil
+	}
+	ptrSize := p.proc.PtrSize()
+	var recs []AllocRecord
+	// mbuckets is a *bucket, wrapped in an atomic.UnsafePointer since Go 1.21.
+	for a := p.proc.ReadPtr(mb.a); a != 0; {
+		b := region{p: p.proc, a: a, typ: bucketType}
+		// The bucket is followed by its stack, then its memRecord.
+		nstk := int64(b.Field("nstk").Uintptr())
+		stk := a.Add(bucketType.Size)
+		r := AllocRecord{Size: int64(b.Field("size").Uintptr())}
+		for i := int64(0); i < nstk; i++ {
+			r.Stack = append(r.Stack, p.proc.ReadPtr(stk.Add(i*ptrSize)))
+		}
+		mp := region{p: p.proc, a: stk.Add(nstk * ptrSize), typ: memRecordType}
+		cycles := []region{mp.Field("active")}
+		for i, future := int64(0), mp.Field("future"); i < future.ArrayLen(); i++ {
+			cycles = append(cycles, future.ArrayIndex(i))
+		}
+		for _, c := range cycles {
+			r.Allocs += int64(c.Field("allocs").Uintptr())
+			r.Frees += int64(c.Field("frees").Uintptr())
+		}
+		recs = append(recs, r)
+		a = b.Field("allnext").Address()
+	}
+	return recs
+}
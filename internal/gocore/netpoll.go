@@ -0,0 +1,90 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"sort"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// A NetPoller is a file descriptor registered with the runtime's
+// network poller, for an internal/poll.FD such as an os.File's or a
+// net.Conn's.
+type NetPoller struct {
+	Addr core.Address // address of the runtime.pollDesc
+	FD   int64        // the file descriptor
+
+	// Reader and Writer are the goroutines blocked reading from and
+	// writing to FD ("IO wait"), or nil if there are none.
+	Reader, Writer *Goroutine
+
+	// ReadDeadline and WriteDeadline are the deadlines for I/O on FD,
+	// in the runtime's monotonic clock (nanotime): 0 if there is none,
+	// and -1 if it has passed.
+	ReadDeadline, WriteDeadline int64
+
+	Closing bool // FD is being closed
+}
+
+// NetPollers returns the file descriptors registered with the network
+// poller, sorted by FD.
+func (p *Process) NetPollers() []NetPoller {
+	// The runtime doesn't keep a list of the pollDescs in use. They are
+	// allocated in blocks by persistentalloc, which does keep a list of
+	// the chunks it allocates from, and each one in use points to itself
+	// (see runtime.poll_runtime_pollOpen). Free ones are on pollcache's
+	// list.
+	pdType := p.rtTypeByName["runtime.pollDesc"]
+	chunks, ok := p.rtGlobals["persistentChunks"]
+	cache, ok2 := p.rtGlobals["pollcache"]
+	if pdType == nil || !ok || !ok2 {
+		return nil
+	}
+	chunkSize, ok := p.rtConsts.find("runtime.persistentChunkSize")
+	if !ok {
+		chunkSize = 256 << 10
+	}
+	free := map[core.Address]bool{}
+	for a := cache.Field("first").Address(); a != 0; a = p.proc.ReadPtr(a.Add(pdType.field("link").Off)) {
+		free[a] = true
+	}
+	gs := map[core.Address]*Goroutine{}
+	for _, g := range p.goroutines {
+		gs[g.Addr()] = g
+	}
+	// waiter returns the goroutine parked on the semaphore at a, if any.
+	// It otherwise holds pdNil, pdReady or pdWait: 0, 1 or 2.
+	waiter := func(a core.Address) *Goroutine {
+		return gs[p.proc.ReadPtr(a)]
+	}
+
+	ptrSize := p.proc.PtrSize()
+	selfOff := pdType.field("self").Off
+	var pollers []NetPoller
+	// The first word of each chunk links to the next one.
+	for c := p.proc.ReadPtr(chunks.a); c != 0; c = p.proc.ReadPtr(c) {
+		for a := c.Add(ptrSize); a.Add(pdType.Size) <= c.Add(chunkSize); a = a.Add(ptrSize) {
+			if p.proc.ReadPtr(a.Add(selfOff)) != a || free[a] {
+				continue
+			}
+			pd := region{p: p.proc, a: a, typ: pdType}
+			pollers = append(pollers, NetPoller{
+				Addr:          a,
+				FD:            int64(pd.Field("fd").Uintptr()),
+				Reader:        waiter(pd.Field("rg").a),
+				Writer:        waiter(pd.Field("wg").a),
+				ReadDeadline:  pd.Field("rd").Int(),
+				WriteDeadline: pd.Field("wd").Int(),
+				Closing:       pd.Field("closing").Bool(),
+			})
+			a = a.Add(pdType.Size - ptrSize)
+		}
+	}
+	sort.Slice(pollers, func(i, j int) bool {
+		return pollers[i].FD < pollers[j].FD
+	})
+	return pollers
+}
@@ -5,8 +5,12 @@
 package gocore
 
 import (
+	"cmp"
+	"fmt"
+	"hash/fnv"
 	"iter"
 	"math/bits"
+	"slices"
 
 	"golang.org/x/debug/internal/core"
 )
@@ -16,8 +20,9 @@ import (
 type Object core.Address
 
 // markObjects finds all the live objects in the heap and marks them
-// in the p.heapInfo mark fields.
-func (p *Process) markObjects() {
+// in the p.heapInfo mark fields. If keep is non-nil, only objects
+// reachable from roots for which keep returns true are marked.
+func (p *Process) markObjects(keep func(r *Root) bool) {
 	ptrSize := p.proc.PtrSize()
 
 	// number of live objects found so far
@@ -27,6 +32,14 @@ func (p *Process) markObjects() {
 
 	var q []Object
 
+	// For Options.Progress. Unreachable objects aren't found, so this
+	// is only a bound.
+	var allocated int64
+	for _, cs := range p.spanStats {
+		allocated += cs.Alloc
+	}
+	p.report("objects", 0, allocated)
+
 	// Function to call when we find a new pointer.
 	add := func(x core.Address) {
 		h := p.heap.get(x)
@@ -45,6 +58,9 @@ func (p *Process) markObjects() {
 		}
 		h.mark |= uint64(1) << b
 		n++
+		if n%(1<<14) == 0 {
+			p.report("objects", min(int64(n), allocated), allocated)
+		}
 		live += h.size
 		q = append(q, Object(x))
 	}
@@ -61,6 +77,9 @@ func (p *Process) markObjects() {
 	// Not a huge deal given that we'll just ignore outright bad pointers, but
 	// we may accidentally mark some objects as live erroneously.
 	p.ForEachRoot(func(r *Root) bool {
+		if keep != nil && !keep(r) {
+			return true
+		}
 		p.forEachRootPtr(r, func(_ int64, ptr core.Address) bool {
 			add(ptr)
 			return true
@@ -83,7 +102,9 @@ func (p *Process) markObjects() {
 		}
 	}
 
+	p.report("objects", allocated, allocated)
 	p.nObj = n
+	p.liveBytes = live
 
 	// Initialize firstIdx fields in the heapInfo, for fast object index lookups.
 	n = 0
@@ -141,6 +162,8 @@ func (p *Process) IsPtr(a core.Address) bool {
 
 // FindObject finds the object containing a.  Returns that object and the offset within
 // that object to which a points.
+// a may point anywhere in the object, not just at its start, so an interior
+// pointer such as &s.f or &a[i] finds the whole allocation.
 // Returns 0,0 if a doesn't point to a live heap object.
 func (p *Process) FindObject(a core.Address) (Object, int64) {
 	// Round down to the start of an object.
@@ -168,7 +191,20 @@ func (p *Process) findObjectIndex(a core.Address) (int, int64) {
 	return h.firstIdx + bits.OnesCount64(h.mark&(uint64(1)<<(uint64(x)%heapInfoSize/8)-1)), off
 }
 
-// ForEachObject calls fn with each object in the Go heap.
+// NumObjects returns the number of live objects in the Go heap,
+// the number ForEachObject visits.
+func (p *Process) NumObjects() int {
+	return p.nObj
+}
+
+// LiveBytes returns the total size in bytes of the live objects in
+// the Go heap.
+func (p *Process) LiveBytes() int64 {
+	return p.liveBytes
+}
+
+// ForEachObject calls fn with each object in the Go heap,
+// in increasing address order.
 // If fn returns false, ForEachObject returns immediately.
 func (p *Process) ForEachObject(fn func(x Object) bool) {
 	for a, h := range p.heap.all() {
@@ -184,6 +220,30 @@ func (p *Process) ForEachObject(fn func(x Object) bool) {
 	}
 }
 
+// ForEachObjectIn calls fn with each object in the Go heap that starts
+// in [min, max), in increasing address order. Unlike filtering
+// ForEachObject, it only looks at the part of the heap in the range.
+// If fn returns false, ForEachObjectIn returns immediately.
+func (p *Process) ForEachObjectIn(min, max core.Address, fn func(x Object) bool) {
+	for a, h := range p.heap.allIn(min, max) {
+		m := h.mark
+		for m != 0 {
+			j := bits.TrailingZeros64(m)
+			m &= m - 1
+			x := a + core.Address(j*8)
+			if x < min {
+				continue
+			}
+			if x >= max {
+				return
+			}
+			if !fn(Object(x)) {
+				return
+			}
+		}
+	}
+}
+
 // ForEachRoot calls fn with each garbage collection root.
 // If fn returns false, ForEachRoot returns immediately.
 func (p *Process) ForEachRoot(fn func(r *Root) bool) {
@@ -222,6 +282,36 @@ func (p *Process) Type(x Object) (*Type, int64) {
 	return p.types[i].t, p.types[i].r
 }
 
+// ReadObject returns a copy of the contents of x.
+// Unlike reading x's memory with core.Process.ReadAt, it never panics.
+// Parts of x that are missing from the core file (for example, pages
+// released with MADV_DONTNEED) read as zero, and complete is false.
+func (p *Process) ReadObject(x Object) (b []byte, complete bool) {
+	return p.readMem(core.Address(x), p.Size(x))
+}
+
+// readMem returns a copy of the n bytes of memory at a, like ReadObject.
+func (p *Process) readMem(a core.Address, n int64) (b []byte, complete bool) {
+	// Core file mappings are 4K-aligned, so each 4K page is either
+	// entirely readable or not at all.
+	const pageSize = 1 << 12
+	b = make([]byte, n)
+	complete = true
+	for off := int64(0); off < int64(len(b)); {
+		n := pageSize - int64(a.Add(off))%pageSize
+		if n > int64(len(b))-off {
+			n = int64(len(b)) - off
+		}
+		if p.proc.ReadableN(a.Add(off), n) {
+			p.proc.ReadAt(b[off:off+n], a.Add(off))
+		} else {
+			complete = false
+		}
+		off += n
+	}
+	return b, complete
+}
+
 // ForEachPtr calls fn for all heap pointers it finds in x.
 // It calls fn with:
 //
@@ -229,6 +319,8 @@ func (p *Process) Type(x Object) (*Type, int64) {
 //	the pointed-to object y
 //	the offset in y where the pointer points.
 //
+// A nonzero offset in y means an interior pointer, such as &s[3] or
+// &v.f; FieldPath(y, off) names what it points to.
 // If fn returns false, ForEachPtr returns immediately.
 // For an edge from an object to its finalizer, the first argument
 // passed to fn will be -1. (TODO: implement)
@@ -249,6 +341,83 @@ func (p *Process) ForEachPtr(x Object, fn func(int64, Object, int64) bool) {
 	}
 }
 
+// FieldPath returns the path of the field at offset off in object x,
+// relative to the object, as Type.FieldNameAt does for a type: for
+// example ".next", or "[3].x" if x holds an array of its type. A "?"
+// follows an array index past the part of x known to hold its type.
+// If x's type is unknown, FieldPath returns "f" and the offset,
+// such as "f16".
+func (p *Process) FieldPath(x Object, off int64) string {
+	typ, repeat := p.Type(x)
+	if typ == nil {
+		return fmt.Sprintf("f%d", off)
+	}
+	n := p.Size(x) / typ.Size
+	i := off / typ.Size
+	if i == 0 && repeat == 1 {
+		// Probably a singleton object, no need for array notation.
+		return typ.FieldNameAt(off)
+	}
+	if i >= n {
+		// Partial space at the end of the object - the type can't be complete.
+		return fmt.Sprintf("f%d", off)
+	}
+	q := ""
+	if i >= repeat {
+		// Past the known repeat section, add a ? because we're not sure about the type.
+		q = "?"
+	}
+	return fmt.Sprintf("[%d]%s%s", i, typ.FieldNameAt(off-i*typ.Size), q)
+}
+
+// ObjectFingerprint returns a hash of the shape of x: its size, its type,
+// and, for each pointer in it, the pointer's offset and the type of the
+// object pointed to. It doesn't depend on any addresses, so it can be
+// used to pair up objects that probably correspond between two cores,
+// for example snapshots of the same program taken at different times.
+// Different objects may have the same fingerprint.
+func (p *Process) ObjectFingerprint(x Object) uint64 {
+	h := fnv.New64a()
+	typeID := func(y Object) {
+		if t, r := p.Type(y); t != nil {
+			fmt.Fprintf(h, "%s*%d,", t.Name, r)
+		} else {
+			fmt.Fprintf(h, "?%d,", p.Size(y))
+		}
+	}
+	fmt.Fprintf(h, "%d:", p.Size(x))
+	typeID(x)
+	p.ForEachPtr(x, func(i int64, y Object, j int64) bool {
+		fmt.Fprintf(h, "%d->%d:", i, j)
+		typeID(y)
+		return true
+	})
+	return h.Sum64()
+}
+
+// ForEachReachable calls fn with x and then with every object reachable
+// from x by following heap pointers. Objects are visited in breadth-first
+// order, and each object is visited only once.
+// If fn returns false, ForEachReachable returns immediately.
+func (p *Process) ForEachReachable(x Object, fn func(Object) bool) {
+	seen := map[Object]bool{x: true}
+	q := []Object{x}
+	for len(q) > 0 {
+		y := q[0]
+		q = q[1:]
+		if !fn(y) {
+			return
+		}
+		p.ForEachPtr(y, func(_ int64, z Object, _ int64) bool {
+			if !seen[z] {
+				seen[z] = true
+				q = append(q, z)
+			}
+			return true
+		})
+	}
+}
+
 // ForEachRootPtr behaves like ForEachPtr but it starts with a Root instead of an Object.
 func (p *Process) ForEachRootPtr(r *Root, fn func(int64, Object, int64) bool) {
 	p.forEachRootPtr(r, func(off int64, ptr core.Address) bool {
@@ -262,10 +431,44 @@ func (p *Process) ForEachRootPtr(r *Root, fn func(int64, Object, int64) bool) {
 	})
 }
 
+// ForEachGlobalRootPtr calls fn for each pointer from a global root into
+// the heap. The arguments are the root, the offset of the pointer in it,
+// the object pointed to, and the offset in that object, as in ForEachPtr.
+// If fn returns false, ForEachGlobalRootPtr returns immediately.
+func (p *Process) ForEachGlobalRootPtr(fn func(r *Root, i int64, y Object, j int64) bool) {
+	p.forEachRootsPtr(p.globals, fn)
+}
+
+// ForEachFrameRootPtr is like ForEachGlobalRootPtr, but for the roots in
+// the stack frames of g.
+func (p *Process) ForEachFrameRootPtr(g *Goroutine, fn func(r *Root, i int64, y Object, j int64) bool) {
+	for _, f := range g.frames {
+		if !p.forEachRootsPtr(f.roots, fn) {
+			return
+		}
+	}
+}
+
+// forEachRootsPtr calls fn for each heap pointer in roots. It reports
+// whether fn always returned true.
+func (p *Process) forEachRootsPtr(roots []*Root, fn func(r *Root, i int64, y Object, j int64) bool) bool {
+	for _, r := range roots {
+		ok := true
+		p.ForEachRootPtr(r, func(i int64, y Object, j int64) bool {
+			ok = fn(r, i, y, j)
+			return ok
+		})
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
 // forEachRootPtr walks all the pointers of the root, even if they don't point to
 // a valid object.
 func (p *Process) forEachRootPtr(r *Root, fn func(int64, core.Address) bool) {
-	ptrBuf := make([]byte, 8)
+	ptrBuf := make([]byte, p.proc.PtrSize())
 	walkRootTypePtrs(p, r, ptrBuf, 0, r.Type, fn)
 }
 
@@ -298,19 +501,32 @@ func (id heapTableID) addr() core.Address {
 	return core.Address(id * heapInfoSize * heapTableSize)
 }
 
+// A heapTable maps addresses to their heapInfo. Like the runtime's arena
+// map, it is a two-level array: the high bits of an address select an L2
+// array from a short list, and the next heapTableL2Bits bits select a
+// heapTableEntry in that array. The heap is usually in one or two
+// contiguous pieces, so the list is short, and lookups need no hashing.
 type heapTable struct {
-	table   map[heapTableID]*heapTableEntry
-	entries []heapTableID
+	l1      []heapTableL1 // sorted by key
 	ptrSize uint64
 }
 
+type heapTableL1 struct {
+	key uint64 // heapTableID >> heapTableL2Bits
+	l2  *[1 << heapTableL2Bits]*heapTableEntry
+}
+
 func heapTableIndex(a core.Address) (heapTableID, uint64) {
 	return heapTableID(a / heapInfoSize / heapTableSize), uint64(a / heapInfoSize % heapTableSize)
 }
 
 // Heap info structures cover 9 bits of address.
 // A page table entry covers 20 bits of address (1MB).
-const heapTableSize = 1 << 11
+// An L2 array covers 34 bits of address (16GB).
+const (
+	heapTableSize   = 1 << 11
+	heapTableL2Bits = 14
+)
 
 type heapTableEntry [heapTableSize]heapInfo
 
@@ -330,7 +546,14 @@ func (ht *heapTable) setIsPointer(a core.Address) {
 // if a is not a heap address.
 func (ht *heapTable) get(a core.Address) *heapInfo {
 	k, i := heapTableIndex(a)
-	t := ht.table[k]
+	k1, k2 := uint64(k)>>heapTableL2Bits, uint64(k)%(1<<heapTableL2Bits)
+	j, ok := slices.BinarySearchFunc(ht.l1, k1, func(e heapTableL1, k1 uint64) int {
+		return cmp.Compare(e.key, k1)
+	})
+	if !ok {
+		return nil
+	}
+	t := ht.l1[j].l2[k2]
 	if t == nil {
 		return nil
 	}
@@ -345,27 +568,78 @@ func (ht *heapTable) get(a core.Address) *heapInfo {
 // creates it, marking it as a heap address.
 func (ht *heapTable) getOrCreate(a core.Address) *heapInfo {
 	k, i := heapTableIndex(a)
-	t := ht.table[k]
+	k1, k2 := uint64(k)>>heapTableL2Bits, uint64(k)%(1<<heapTableL2Bits)
+	j, ok := slices.BinarySearchFunc(ht.l1, k1, func(e heapTableL1, k1 uint64) int {
+		return cmp.Compare(e.key, k1)
+	})
+	if !ok {
+		ht.l1 = slices.Insert(ht.l1, j, heapTableL1{key: k1, l2: new([1 << heapTableL2Bits]*heapTableEntry)})
+	}
+	l2 := ht.l1[j].l2
+	t := l2[k2]
 	if t == nil {
 		t = new(heapTableEntry)
 		for j := 0; j < heapTableSize; j++ {
 			t[j].firstIdx = -1
 		}
-		ht.table[k] = t
-		ht.entries = append(ht.entries, k)
+		l2[k2] = t
 	}
 	return &t[i]
 }
 
+// all returns the heap info for every address in the table, in address order.
 func (ht *heapTable) all() iter.Seq2[core.Address, *heapInfo] {
 	return func(yield func(core.Address, *heapInfo) bool) {
-		for _, k := range ht.entries {
-			e := ht.table[k]
-			for i := range e {
-				h := &e[i]
-				a := k.addr() + core.Address(uint64(i)*heapInfoSize)
-				if !yield(a, h) {
-					return
+		for _, e := range ht.l1 {
+			for k2, t := range e.l2 {
+				if t == nil {
+					continue
+				}
+				k := heapTableID(e.key<<heapTableL2Bits + uint64(k2))
+				for i := range t {
+					h := &t[i]
+					a := k.addr() + core.Address(uint64(i)*heapInfoSize)
+					if !yield(a, h) {
+						return
+					}
+				}
+			}
+		}
+	}
+}
+
+// allIn is like all, but returns only the heap info for the part of the
+// table that covers [min, max).
+func (ht *heapTable) allIn(min, max core.Address) iter.Seq2[core.Address, *heapInfo] {
+	return func(yield func(core.Address, *heapInfo) bool) {
+		if min >= max {
+			return
+		}
+		lo, _ := heapTableIndex(min)
+		hi, _ := heapTableIndex(max - 1)
+		for _, e := range ht.l1 {
+			if e.key < uint64(lo)>>heapTableL2Bits || e.key > uint64(hi)>>heapTableL2Bits {
+				continue
+			}
+			for k2, t := range e.l2 {
+				k := heapTableID(e.key<<heapTableL2Bits + uint64(k2))
+				if t == nil || k < lo {
+					continue
+				}
+				if k > hi {
+					break
+				}
+				for i := range t {
+					a := k.addr() + core.Address(uint64(i)*heapInfoSize)
+					if a.Add(heapInfoSize) <= min {
+						continue
+					}
+					if a >= max {
+						break
+					}
+					if !yield(a, &t[i]) {
+						return
+					}
 				}
 			}
 		}
@@ -0,0 +1,155 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"strconv"
+	"strings"
+
+	"golang.org/x/debug/internal/core"
+)
+
+// A Panic is a panic in progress on a goroutine.
+type Panic struct {
+	Type *Type        // dynamic type of the value passed to panic; nil if it was nil
+	Addr core.Address // address of the value passed to panic; 0 if it was nil
+
+	// Message is the panic value as the runtime prints it after
+	// "panic: ", for the values gocore can format without running
+	// code in the inferior: strings, integers, runtime errors and
+	// errors.New errors. A value of a defined string or integer type
+	// includes the type, as in main.T("x"). Otherwise it is "".
+	Message string
+}
+
+// CrashingGoroutine returns the goroutine that was crashing the program
+// when the core was taken, or nil if there is none. That is one dying
+// of a panic or a fatal error (runtime.fatalpanic, runtime.fatalthrow),
+// or, failing that, one that is panicking (runtime.gopanic).
+// If the goroutine is panicking, CrashingGoroutine also returns the
+// innermost panic; for a fatal error that isn't a panic, it returns nil.
+func (p *Process) CrashingGoroutine() (*Goroutine, *Panic) {
+	var panicking *Goroutine
+	for _, g := range p.goroutines {
+		// The frames that crashed may be on the system stack,
+		// or past a signal frame gocore can't unwind through, so
+		// also ask the runtime.
+		if p.hasFrame(g, "runtime.fatalpanic", "runtime.fatalthrow") || p.mDying(g) {
+			return g, p.goroutinePanic(g)
+		}
+		if panicking == nil && (p.hasFrame(g, "runtime.gopanic") || g.r.Field("_panic").Address() != 0) {
+			panicking = g
+		}
+	}
+	if panicking == nil {
+		return nil, nil
+	}
+	return panicking, p.goroutinePanic(panicking)
+}
+
+// hasFrame reports whether g has a frame of one of the named functions.
+func (p *Process) hasFrame(g *Goroutine, names ...string) bool {
+	for _, f := range g.frames {
+		for _, name := range names {
+			if f.f.name == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// mDying reports whether g's M has started crashing the program,
+// after a panic nobody recovered or a fatal error.
+func (p *Process) mDying(g *Goroutine) bool {
+	if g.r.Field("m").Address() == 0 {
+		return false
+	}
+	m := g.r.Field("m").Deref()
+	for _, name := range []string{"dying", "throwing"} {
+		if !m.HasField(name) {
+			continue
+		}
+		// throwing is an int32 before Go 1.20, and a throwType after.
+		if f := m.Field(name); f.typ.Size == 4 && p.proc.ReadUint32(f.a) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// goroutinePanic returns g's innermost panic, or nil if it isn't panicking.
+func (p *Process) goroutinePanic(g *Goroutine) *Panic {
+	if g.r.Field("_panic").Address() == 0 {
+		return nil
+	}
+	arg := g.r.Field("_panic").Deref().Field("arg")
+	typ, data, _ := p.interfaceValue(arg.a, arg.typ, p.proc)
+	if typ == nil {
+		return &Panic{}
+	}
+	return &Panic{Type: typ, Addr: data, Message: p.panicMessage(typ, data)}
+}
+
+// panicMessage formats the panic value of type typ at a, the way
+// runtime.printpanicval would, or returns "" if it can't.
+func (p *Process) panicMessage(typ *Type, a core.Address) string {
+	var msg string
+	switch {
+	case typ.Name == "runtime.errorString":
+		return "runtime error: " + region{p: p.proc, a: a, typ: typ}.String()
+	case typ.Name == "runtime.plainError":
+		// An error whose Error method returns the string itself.
+		return region{p: p.proc, a: a, typ: typ}.String()
+	case typ.Kind == KindPtr && typ.Elem != nil && typ.Elem.Name == "errors.errorString":
+		x := p.proc.ReadPtr(a)
+		if f := typ.Elem.field("s"); x != 0 && f != nil && f.Type.Kind == KindString {
+			return region{p: p.proc, a: x.Add(f.Off), typ: f.Type}.String()
+		}
+		return ""
+	case typ.Kind == KindString:
+		msg = region{p: p.proc, a: a, typ: typ}.String()
+	case typ.Kind == KindInt:
+		msg = strconv.FormatInt(p.readInt(a, typ.Size), 10)
+	case typ.Kind == KindUint:
+		x := uint64(p.readInt(a, typ.Size))
+		if typ.Size < 8 {
+			x &= 1<<(8*typ.Size) - 1
+		}
+		msg = strconv.FormatUint(x, 10)
+	default:
+		return ""
+	}
+	if !strings.Contains(typ.Name, ".") {
+		// A predeclared type, like string or int.
+		return msg
+	}
+	// The runtime prints an error or Stringer using its method,
+	// which gocore can't run.
+	for _, m := range p.Methods(typ) {
+		if m.Name == "Error" || m.Name == "String" {
+			return ""
+		}
+	}
+	// Values of other defined types are printed with their type,
+	// like main.T("x") or main.N(5).
+	if typ.Kind == KindString {
+		return typ.Name + `("` + msg + `")`
+	}
+	return typ.Name + "(" + msg + ")"
+}
+
+// readInt reads the size-byte integer at a, sign-extending it.
+func (p *Process) readInt(a core.Address, size int64) int64 {
+	switch size {
+	case 1:
+		return int64(p.proc.ReadInt8(a))
+	case 2:
+		return int64(p.proc.ReadInt16(a))
+	case 4:
+		return int64(p.proc.ReadInt32(a))
+	}
+	return p.proc.ReadInt64(a)
+}
@@ -12,6 +12,7 @@ import (
 	"fmt"
 	"iter"
 	"math/bits"
+	"runtime/debug"
 	"slices"
 	"sort"
 	"strings"
@@ -24,6 +25,7 @@ import (
 )
 
 // A Process represents the state of a Go process that core dumped.
+// Once loaded, it is safe for concurrent use by multiple goroutines.
 type Process struct {
 	proc         *core.Process
 	buildVersion string
@@ -31,8 +33,9 @@ type Process struct {
 	// Index of heap objects and pointers.
 	heap *heapTable
 
-	// number of live objects
-	nObj int
+	// number of live objects, and their total size in bytes
+	nObj      int
+	liveBytes int64
 
 	goroutines []*Goroutine
 
@@ -42,7 +45,7 @@ type Process struct {
 
 	// A module is a loadable unit. Most Go programs have 1, programs
 	// which load plugins will have more.
-	modules []*module
+	modules []*Module
 
 	// Maps core.Address to functions.
 	funcTab *funcTab
@@ -52,11 +55,25 @@ type Process struct {
 
 	// Fundamental type mappings extracted from the core.
 	dwarfTypeMap map[dwarf.Type]*Type
-	rtTypeByName map[string]*Type // Core runtime types only, from DWARF.
+	runtimeOnly  bool             // dwarfTypeMap is from Options.RuntimeDWARF, not the executable
+	rtTypeByName map[string]*Type // Core runtime and sync types only, from DWARF.
+	rtTypeMu     sync.Mutex       // guards rtTypeMap, which runtimeType2Type memoizes into
 	rtTypeMap    map[core.Address]*Type
 
 	// Memory usage breakdown.
-	stats *Statistic
+	stats     *Statistic
+	spanStats []SpanClassStat // sorted by span class
+	spans     []SpanInfo      // in-use heap spans, sorted by address
+	specials  []Special       // special records, in span address order
+
+	// Runtime types of large objects, from their spans.
+	largeTypes map[core.Address]core.Address
+
+	conservativeFrames bool // see FlagConservativeFrames
+
+	typeNameFormatter func(string) string // see SetTypeNameFormatter
+
+	progress func(phase string, done, total int64) // see Options.Progress
 
 	// Global roots.
 	globals []*Root
@@ -75,6 +92,8 @@ type Process struct {
 	// Sorted list of all roots, sorted by id.
 	rootIdx []*Root
 	nRoots  int
+
+	warnings []string // warnings generated during loading
 }
 
 type reverseEdge struct {
@@ -83,31 +102,130 @@ type reverseEdge struct {
 	rOff int64        // Offset of pointer in root.
 }
 
+// Flags control optional behavior of CoreWithOptions.
+type Flags uint32
+
+const (
+	// FlagStrictTypes makes gocore check that all DWARF types describing
+	// the same runtime type (or the same low-level runtime type name)
+	// agree with each other. Disagreements are reported by Warnings.
+	FlagStrictTypes Flags = 1 << iota
+
+	// FlagConservativeFrames makes gocore scan frames whose stack maps
+	// are missing or can't be read, such as those of assembly functions
+	// with locals, conservatively: every word of the frame that points
+	// into the heap is taken to be a live pointer (see Frame.Conservative).
+	// By default, such frames are taken to hold no pointers.
+	FlagConservativeFrames
+)
+
+// Options holds optional settings for CoreWithOptions.
+// The zero value gives the same behavior as Core.
+type Options struct {
+	Flags Flags
+
+	// Roots, if non-nil, selects the roots from which live objects
+	// are found: only objects reachable from roots for which Roots
+	// returns true are indexed and typed. All roots are still
+	// reported by ForEachRoot. Objects reachable only from other
+	// roots are treated as garbage, so the heap statistics
+	// undercount live memory.
+	Roots func(r *Root) bool
+
+	// RuntimeDWARF, if non-nil, is used in place of the DWARF of the
+	// core's executable, for binaries stripped of it. It must come
+	// from a binary built by the same Go toolchain for the same
+	// GOARCH: only the runtime's data structure layouts and constants
+	// are taken from it, and the runtime's globals are found through
+	// the executable's symbol table instead. Program types, globals
+	// and local variables are unknown, so heap objects are typed only
+	// from runtime type descriptors.
+	RuntimeDWARF *dwarf.Data
+
+	// Progress, if non-nil, is called from time to time while the core
+	// is read, which can take minutes for a large one. It reports how
+	// far each phase has got: done of total steps. The phases are, in
+	// order, "dwarf" (reading types and globals, one step), "spans",
+	// "goroutines" and "objects" (finding the live heap objects). The
+	// objects' total is the number of allocated objects, of which only
+	// the live ones are found, so the phase may end early. Each phase's
+	// last call has done == total.
+	Progress func(phase string, done, total int64)
+}
+
 // Core takes a loaded core file and extracts Go information from it.
 func Core(proc *core.Process) (p *Process, err error) {
-	p = &Process{proc: proc}
+	return CoreWithOptions(proc, Options{})
+}
+
+// CoreSubgraph is like Core, but only finds the objects reachable from
+// the roots for which roots returns true. On large cores, this is much
+// faster than finding every live object. See Options.Roots.
+func CoreSubgraph(proc *core.Process, roots func(r *Root) bool) (*Process, error) {
+	return CoreWithOptions(proc, Options{Roots: roots})
+}
+
+// CoreWithOptions is like Core, but allows the caller to adjust
+// how the core file is processed.
+func CoreWithOptions(proc *core.Process, opts Options) (p *Process, err error) {
+	p = &Process{proc: proc, progress: opts.Progress}
+	strict := opts.Flags&FlagStrictTypes != 0
+	p.conservativeFrames = opts.Flags&FlagConservativeFrames != 0
 
 	// Initialize everything that just depends on DWARF.
-	p.dwarfTypeMap, p.rtTypeMap, err = readDWARFTypes(proc)
+	d, err := proc.DWARF()
+	syms, symErr := proc.Symbols()
+	// It's OK if typBase is 0 and not present. We won't be able to type the heap, probably,
+	// but it may still be useful (though painful) to someone to try and debug the core, so don't
+	// error out here.
+	typBase := syms["runtime.types"]
+	var globalSyms map[string]core.Address
+	if opts.RuntimeDWARF != nil {
+		if symErr != nil {
+			return nil, fmt.Errorf("runtime-only DWARF needs the executable's symbol table: %v", symErr)
+		}
+		// The runtime type offsets in the DWARF are the other binary's.
+		d, err, typBase, globalSyms = opts.RuntimeDWARF, nil, 0, syms
+		p.runtimeOnly = true
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DWARF: %v", err)
+	}
+	p.report("dwarf", 0, 1)
+	p.dwarfTypeMap, p.rtTypeMap, p.warnings, err = readDWARFTypes(proc, d, typBase, strict)
 	if err != nil {
 		return nil, err
 	}
 	p.rtTypeByName = make(map[string]*Type)
+	var conflicts []string
+	byName := make(map[string]*Type) // non-typedef types only, for strict checking
 	for dt, t := range p.dwarfTypeMap {
 		// Make an index of some low-level types we'll need unambiguous access to.
-		if name := gocoreName(dt); strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "internal/abi.") || strings.HasPrefix(name, "unsafe.") || !strings.Contains(name, ".") {
+		if name := t.Name; strings.HasPrefix(name, "runtime.") || strings.HasPrefix(name, "internal/abi.") || strings.HasPrefix(name, "unsafe.") || strings.HasPrefix(name, "sync.") || strings.HasPrefix(name, "sync/atomic.") || !strings.Contains(name, ".") {
 			// Sometimes there's duplicate types in the DWARF. That's fine, they're always the same.
+			// In strict mode, check that claim. Typedefs are skipped, as the
+			// eface and iface typedefs intentionally differ from their bases.
+			if _, typedef := dt.(*dwarf.TypedefType); strict && !typedef {
+				if old := byName[name]; old != nil && !sameType(old, t) {
+					conflicts = append(conflicts, fmt.Sprintf("conflicting DWARF types named %s: %s", name, typeConflict(old, t)))
+				}
+				byName[name] = t
+			}
 			p.rtTypeByName[name] = t
 		}
 	}
-	p.rtConsts, err = readDWARFConstants(proc)
+	// Map iteration order is random, so sort to keep the output stable.
+	slices.Sort(conflicts)
+	p.warnings = append(p.warnings, conflicts...)
+	p.rtConsts, err = readDWARFConstants(d)
 	if err != nil {
 		return nil, err
 	}
-	p.globals, err = readDWARFGlobals(proc, &p.nRoots, p.dwarfTypeMap)
+	p.globals, err = readDWARFGlobals(proc, d, globalSyms, &p.nRoots, p.dwarfTypeMap)
 	if err != nil {
 		return nil, err
 	}
+	p.report("dwarf", 1, 1)
 
 	// Find runtime globals we care about. Initialize regions for them.
 	p.rtGlobals = make(map[string]region)
@@ -145,9 +263,13 @@ func Core(proc *core.Process) (p *Process, err error) {
 	}
 
 	// Read stack and register variables from DWARF.
-	p.dwarfVars, err = readDWARFVars(proc, p.funcTab, p.dwarfTypeMap)
-	if err != nil {
-		return nil, err
+	if !p.runtimeOnly {
+		p.dwarfVars, err = readDWARFVars(proc, d, p.funcTab, p.dwarfTypeMap)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		p.warnings = append(p.warnings, "no DWARF for the executable: program types, globals and local variables are unknown")
 	}
 
 	// Read goroutines.
@@ -158,15 +280,42 @@ func Core(proc *core.Process) (p *Process, err error) {
 	// From this point on, all roots are found, initialized, and ready to use.
 
 	// Find all the objects from the roots.
-	p.markObjects()
+	p.markObjects(opts.Roots)
 	return p, nil
 }
 
+// report calls the Options.Progress callback, if any.
+func (p *Process) report(phase string, done, total int64) {
+	if p.progress != nil {
+		p.progress(phase, done, total)
+	}
+}
+
+// Warnings returns any warnings generated while reading the core.
+func (p *Process) Warnings() []string {
+	return p.warnings
+}
+
 // Process returns the core.Process used to construct this Process.
 func (p *Process) Process() *core.Process {
 	return p.proc
 }
 
+// Arch returns the architecture of the inferior, as a GOARCH value.
+func (p *Process) Arch() string {
+	return p.proc.Arch()
+}
+
+// PtrSize returns the size in bytes of a pointer in the inferior.
+func (p *Process) PtrSize() int64 {
+	return p.proc.PtrSize()
+}
+
+// ByteOrder returns the byte order of the inferior.
+func (p *Process) ByteOrder() binary.ByteOrder {
+	return p.proc.ByteOrder()
+}
+
 func (p *Process) Goroutines() []*Goroutine {
 	return p.goroutines
 }
@@ -176,21 +325,166 @@ func (p *Process) Stats() *Statistic {
 	return p.stats
 }
 
+// SpanStats returns occupancy statistics for the in-use heap spans,
+// one entry per span class, sorted by class. Unlike Stats, which only
+// reports totals, it shows how the heap is fragmented: many nearly empty
+// spans of one class mean memory is pinned by a few live objects.
+func (p *Process) SpanStats() []SpanClassStat {
+	return p.spanStats
+}
+
+// ForEachSpan calls fn for each in-use heap span, in increasing address
+// order. If fn returns false, ForEachSpan returns immediately.
+func (p *Process) ForEachSpan(fn func(s SpanInfo) bool) {
+	for _, s := range p.spans {
+		if !fn(s) {
+			return
+		}
+	}
+}
+
+// ObjectSpan returns information about the heap span containing x.
+func (p *Process) ObjectSpan(x Object) SpanInfo {
+	a := core.Address(x)
+	i := sort.Search(len(p.spans), func(i int) bool { return p.spans[i].Base > a }) - 1
+	if i < 0 || a >= p.spans[i].Base.Add(p.spans[i].Size) {
+		panic(fmt.Sprintf("object %x is not in an in-use span", a))
+	}
+	return p.spans[i]
+}
+
+// ForEachSpecial calls fn for each special record attached to an object
+// in an in-use heap span. If fn returns false, ForEachSpecial returns
+// immediately. The objects need not be live.
+func (p *Process) ForEachSpecial(fn func(s Special) bool) {
+	for _, s := range p.specials {
+		if !fn(s) {
+			return
+		}
+	}
+}
+
 // BuildVersion returns the Go version that was used to build the inferior binary.
 func (p *Process) BuildVersion() string {
 	return p.buildVersion
 }
 
+// BuildInfo returns the build information embedded in the inferior
+// binary: its main package, the versions of the modules it was built
+// from, and its build settings. This is the same information reported
+// by "go version -m".
+func (p *Process) BuildInfo() (*debug.BuildInfo, error) {
+	modinfo, ok := p.rtGlobals["modinfo"]
+	if !ok {
+		return nil, errors.New("runtime.modinfo not found")
+	}
+	// The linker brackets the build info with 16-byte sentinels.
+	// See runtime/debug.ReadBuildInfo.
+	data := modinfo.String()
+	if len(data) < 32 {
+		return nil, errors.New("binary has no build info")
+	}
+	bi, err := debug.ParseBuildInfo(data[16 : len(data)-16])
+	if err != nil {
+		return nil, err
+	}
+	// As in runtime/debug.ReadBuildInfo, the Go version is stored separately.
+	bi.GoVersion = p.buildVersion
+	return bi, nil
+}
+
 func (p *Process) Globals() []*Root {
 	return p.globals
 }
 
+// ForEachGlobal calls fn with the name, type, and address of each global
+// variable described in DWARF. Globals that don't live at a single address
+// are skipped, as are the unnamed roots added for pointers in data and bss
+// that DWARF doesn't cover. If fn returns false, ForEachGlobal returns
+// immediately.
+func (p *Process) ForEachGlobal(fn func(name string, t *Type, addr core.Address) bool) {
+	for _, r := range p.globals {
+		if r.Name == "unk" || !r.HasAddress() {
+			continue
+		}
+		if !fn(r.Name, r.Type, r.Addr()) {
+			return
+		}
+	}
+}
+
 // FindFunc returns the function which contains the code at address pc, if any.
 func (p *Process) FindFunc(pc core.Address) *Func {
 	return p.funcTab.find(pc)
 }
 
-func forEachGlobalPtr(p *core.Process, modules []*module, f func(core.Address) bool) {
+// FindFuncByName returns the function with the given name, as reported
+// by Func.Name, or nil if there is none.
+func (p *Process) FindFuncByName(name string) *Func {
+	return p.funcTab.findByName(name)
+}
+
+// Modules returns the Go modules loaded into the inferior. The first is
+// the executable's.
+func (p *Process) Modules() []*Module {
+	return p.modules
+}
+
+// An AddrKind describes which part of the inferior's address space
+// an address belongs to.
+type AddrKind uint8
+
+const (
+	AddrUnknown AddrKind = iota // not in any region known to gocore
+	AddrHeap                    // in an in-use heap span
+	AddrStack                   // on a goroutine stack
+	AddrGlobal                  // in a module's data or bss sections
+)
+
+func (k AddrKind) String() string {
+	return [...]string{
+		"unknown",
+		"heap",
+		"stack",
+		"global",
+	}[k]
+}
+
+// Classify reports which part of the inferior's address space a lies in.
+// For stack addresses, it also returns the goroutine owning the stack and,
+// if a lies within an active frame, that frame.
+// For heap addresses, use FindObject to find the containing object.
+func (p *Process) Classify(a core.Address) (AddrKind, *Goroutine, *Frame) {
+	if p.heap.get(a) != nil {
+		return AddrHeap, nil, nil
+	}
+	// Check frames first. Frames running on the signal or system
+	// stack lie outside the goroutine's own stack bounds.
+	for _, g := range p.goroutines {
+		for _, f := range g.frames {
+			if f.min <= a && a < f.max {
+				return AddrStack, g, f
+			}
+		}
+	}
+	for _, g := range p.goroutines {
+		if g.stackLo <= a && a < g.stackHi {
+			return AddrStack, g, nil
+		}
+	}
+	for _, m := range p.modules {
+		for _, s := range [4]string{"noptrdata", "data", "bss", "noptrbss"} {
+			min := core.Address(m.r.Field(s).Uintptr())
+			max := core.Address(m.r.Field("e" + s).Uintptr())
+			if min <= a && a < max {
+				return AddrGlobal, nil, nil
+			}
+		}
+	}
+	return AddrUnknown, nil, nil
+}
+
+func forEachGlobalPtr(p *core.Process, modules []*Module, f func(core.Address) bool) {
 	for _, m := range modules {
 		for _, s := range [2]string{"data", "bss"} {
 			min := core.Address(m.r.Field(s).Uintptr())
@@ -206,7 +500,7 @@ func forEachGlobalPtr(p *core.Process, modules []*module, f func(core.Address) b
 	}
 }
 
-func fixUpGlobals(p *core.Process, modules []*module, globals []*Root, unsafePtrType *Type, nRoots *int) ([]*Root, error) {
+func fixUpGlobals(p *core.Process, modules []*Module, globals []*Root, unsafePtrType *Type, nRoots *int) ([]*Root, error) {
 	slices.SortFunc(globals, func(a, b *Root) int {
 		// Assume all globals have an address.
 		return cmp.Compare(a.Addr(), b.Addr())
@@ -298,7 +592,6 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 
 	// The main goal of this function is to initialize this data structure.
 	heap := &heapTable{
-		table:   make(map[heapTableID]*heapTableEntry),
 		ptrSize: uint64(p.proc.PtrSize()),
 	}
 
@@ -310,6 +603,7 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 		spanTable        int64
 		data             int64
 		bss              int64
+		otherMmap        int64
 		freeSpanSize     int64
 		releasedSpanSize int64
 		manualSpanSize   int64
@@ -320,6 +614,28 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 		manualAllocSize  int64
 		manualFreeSize   int64
 	}
+	// The files holding the program's code: the executable, and any
+	// shared libraries or plugins with Go modules in them. Copy-on-write
+	// mappings of these files are data segments. Those of other files
+	// were mapped by the program.
+	progFiles := map[string]bool{}
+	// The bss of each module, extended to the end of its last page.
+	// It may be part of the data segment's mapping, or have an
+	// anonymous mapping of its own.
+	type addrRange struct{ min, max core.Address }
+	var bss []addrRange
+	const bssPageSize = 1 << 12
+	for _, md := range p.modules {
+		text := core.Address(md.r.Field("text").Uintptr())
+		for _, m := range p.proc.Mappings() {
+			if m.Min() <= text && text < m.Max() {
+				progFiles[mappingFile(m)] = true
+			}
+		}
+		min := core.Address(md.r.Field("bss").Uintptr()).Min(core.Address(md.r.Field("noptrbss").Uintptr()))
+		max := core.Address(md.r.Field("ebss").Uintptr()).Max(core.Address(md.r.Field("enoptrbss").Uintptr()))
+		bss = append(bss, addrRange{min, max.Align(bssPageSize)})
+	}
 	for _, m := range p.proc.Mappings() {
 		size := m.Size()
 		stats.all += size
@@ -329,12 +645,6 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 		case core.Read | core.Exec:
 			stats.text += size
 		case core.Read | core.Write:
-			if m.CopyOnWrite() {
-				// Check if m.file == text's file? That could distinguish
-				// data segment from mmapped file.
-				stats.data += size
-				break
-			}
 			attribute := func(x, y core.Address, p *int64) {
 				a := x.Max(m.Min())
 				b := y.Min(m.Max())
@@ -343,12 +653,25 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 					size -= b.Sub(a)
 				}
 			}
+			for _, r := range bss {
+				attribute(r.min, r.max, &stats.bss)
+			}
+			if m.CopyOnWrite() {
+				if progFiles[mappingFile(m)] {
+					stats.data += size
+				} else {
+					stats.otherMmap += size
+				}
+				break
+			}
+			var inHeap int64 // counted by the heap stats below
 			for _, a := range arenas {
 				attribute(a.spanTableMin, a.spanTableMax, &stats.spanTable)
+				attribute(a.heapMin, a.heapMax, &inHeap)
 			}
-			// Any other anonymous mapping is bss.
-			// TODO: how to distinguish original bss from anonymous mmap?
-			stats.bss += size
+			// Any other anonymous mapping was made by the program or
+			// the runtime: OS thread stacks, C allocations, and so on.
+			stats.otherMmap += size
 		case core.Exec: // Ignore --xp mappings, like Linux's vsyscall=xonly.
 			stats.all -= size // Make the total match again.
 		default:
@@ -369,13 +692,45 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 
 	abiType := p.rtTypeByName["internal/abi.Type"]
 
+	// With the Green Tea GC (go1.25+ with GOEXPERIMENT=greenteagc, and
+	// the default since), spans of small objects end with inline mark
+	// bits, which come after the heap bits:
+	// [objects][heap bits][runtime.spanInlineMarkBits].
+	// Without it, the type is either missing or empty.
+	var inlineMarkSize, inlineMarkClassOff int64
+	inlineMarkClassOff = -1
+	if t := p.rtTypeByName["runtime.spanInlineMarkBits"]; t != nil && t.Size > 0 {
+		inlineMarkSize = t.Size
+		for _, f := range t.Fields {
+			if f.Name == "class" {
+				inlineMarkClassOff = f.Off
+			}
+		}
+		if inlineMarkClassOff < 0 {
+			p.warnings = append(p.warnings, "runtime.spanInlineMarkBits has no class field; can't check the layout of small object spans")
+		}
+	}
+	badInlineMarks := 0 // number of spans whose inline mark bits don't look right
+
+	// Names of the special record kinds, from the runtime._KindSpecial* constants.
+	specialKinds := map[uint8]string{}
+	for name, v := range p.rtConsts {
+		if k, ok := strings.CutPrefix(name, "runtime._KindSpecial"); ok {
+			specialKinds[uint8(v)] = k
+		}
+	}
+
 	// Process spans.
 	if pageSize%heapInfoSize != 0 {
 		return nil, nil, fmt.Errorf("page size not a multiple of %d", heapInfoSize)
 	}
+	classStats := map[uint8]*SpanClassStat{}
 	allspans := mheap.Field("allspans")
 	n := allspans.SliceLen()
 	for i := int64(0); i < n; i++ {
+		if i%1024 == 0 {
+			p.report("spans", i, n)
+		}
 		s := allspans.SliceIndex(i).Deref()
 		min := core.Address(s.Field("startAddr").Uintptr())
 		elemSize := int64(s.Field("elemsize").Uintptr())
@@ -435,6 +790,43 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 			}
 			stats.spanRoundSize += spanSize - n*elemSize
 
+			// Record occupancy of this span for SpanStats.
+			class := s.Field("spanclass").Uint8()
+			cs := classStats[class]
+			if cs == nil {
+				cs = &SpanClassStat{Class: class, ElemSize: elemSize}
+				if class>>1 == 0 {
+					cs.ElemSize = 0 // large objects; each span has its own size
+				}
+				classStats[class] = cs
+			}
+			var nAlloc int64
+			for _, a := range alloc {
+				if a {
+					nAlloc++
+				}
+			}
+			p.spans = append(p.spans, SpanInfo{
+				Base:     min,
+				Size:     nPages * pageSize,
+				Class:    class,
+				ElemSize: elemSize,
+				Slots:    n,
+				Alloc:    nAlloc,
+			})
+			cs.Spans++
+			cs.Bytes += nPages * pageSize
+			cs.Slots += n
+			cs.Alloc += nAlloc
+			cs.Tail += nPages*pageSize - n*elemSize
+			if n > 0 {
+				b := nAlloc * int64(len(cs.Occupancy)) / n
+				if b == int64(len(cs.Occupancy)) {
+					b-- // full spans go in the last bucket
+				}
+				cs.Occupancy[b]++
+			}
+
 			// initialize heap info records for all inuse spans.
 			for a := min; a < max; a += heapInfoSize {
 				h := heap.getOrCreate(a)
@@ -445,10 +837,6 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 			// Process special records.
 			for sp := s.Field("specials"); sp.Address() != 0; sp = sp.Field("next") {
 				sp = sp.Deref() // *special to special
-				if sp.Field("kind").Uint8() != uint8(p.rtConsts.get("runtime._KindSpecialFinalizer")) {
-					// All other specials (just profile records) can't point into the heap.
-					continue
-				}
 				offField := sp.Field("offset")
 				var off int64
 				if offField.typ.Size == p.proc.PtrSize() {
@@ -459,8 +847,27 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 					off = int64(offField.Uint16())
 				}
 				obj := min.Add(off)
-				typ := p.rtTypeByName["runtime.specialfinalizer"]
-				p.globals = append(p.globals, p.makeMemRoot(fmt.Sprintf("finalizer for %x", obj), typ, nil, sp.a))
+				kind := sp.Field("kind").Uint8()
+				name, ok := specialKinds[kind]
+				if !ok {
+					name = fmt.Sprintf("kind %d", kind)
+				}
+				p.specials = append(p.specials, Special{Obj: obj, Kind: name, Addr: sp.a})
+
+				// Finalizers and cleanups hold pointers into the heap.
+				// All other specials (profile records, weak handles, ...)
+				// can't keep anything alive.
+				var typ *Type
+				switch name {
+				case "Finalizer":
+					typ = p.rtTypeByName["runtime.specialfinalizer"]
+				case "Cleanup":
+					typ = p.rtTypeByName["runtime.specialCleanup"]
+				}
+				if typ == nil {
+					continue
+				}
+				p.globals = append(p.globals, p.makeMemRoot(fmt.Sprintf("%s for %x", strings.ToLower(name), obj), typ, nil, sp.a))
 				// TODO: these aren't really "globals", as they
 				// are kept alive by the object they reference being alive.
 				// But we have no way of adding edges from an object to
@@ -475,6 +882,17 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 				// Heap bits in span.
 				bitmapSize := spanSize / int64(heap.ptrSize) / 8
 				bitmapAddr := min.Add(spanSize - bitmapSize)
+				// See runtime.gcUsesSpanInlineMarkBits.
+				if inlineMarkSize > 0 && elemSize >= 16 {
+					imb := min.Add(spanSize - inlineMarkSize)
+					if inlineMarkClassOff >= 0 && p.proc.ReadUint8(imb.Add(inlineMarkClassOff)) != s.Field("spanclass").Uint8() {
+						// The layout isn't what we expect. Better to
+						// miss pointers than to make some up.
+						badInlineMarks++
+						continue
+					}
+					bitmapAddr = bitmapAddr.Add(-inlineMarkSize)
+				}
 				for i := int64(0); i < bitmapSize; i++ {
 					bits := p.proc.ReadUint8(bitmapAddr.Add(int64(i)))
 					for j := int64(0); j < 8; j++ {
@@ -511,9 +929,14 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 						panic("unexpected GC prog on small allocation")
 					}
 					gcdata := typ.Field("GCData").Address()
-					for i := int64(0); i < nptrs; i++ {
-						if p.proc.ReadUint8(gcdata.Add(i/8))>>uint(i%8)&1 != 0 {
-							heap.setIsPointer(min.Add(off + mallocHeaderSize + i*int64(heap.ptrSize)))
+					// The object may be an array of typ; repeat its
+					// pointer mask for each element, as the runtime does.
+					size := int64(typ.Field("Size_").Uintptr())
+					for e := int64(0); size > 0 && e+size <= elemSize-mallocHeaderSize; e += size {
+						for i := int64(0); i < nptrs; i++ {
+							if p.proc.ReadUint8(gcdata.Add(i/8))>>uint(i%8)&1 != 0 {
+								heap.setIsPointer(min.Add(off + mallocHeaderSize + e + i*int64(heap.ptrSize)))
+							}
 						}
 					}
 				}
@@ -529,6 +952,10 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 				// actively allocating a large object.
 				typPtr := s.Field("largeType")
 				if typPtr.Address() != 0 {
+					if p.largeTypes == nil {
+						p.largeTypes = map[core.Address]core.Address{}
+					}
+					p.largeTypes[min] = typPtr.Address()
 					typ := typPtr.Deref()
 					nptrs := int64(typ.Field("PtrBytes").Uintptr()) / int64(heap.ptrSize)
 					kindGCProg, hasGCProgs := p.rtConsts.find("internal/abi.KindGCProg")
@@ -536,9 +963,13 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 						panic("large object's GCProg was not unrolled")
 					}
 					gcdata := typ.Field("GCData").Address()
-					for i := int64(0); i < nptrs; i++ {
-						if p.proc.ReadUint8(gcdata.Add(i/8))>>uint(i%8)&1 != 0 {
-							heap.setIsPointer(min.Add(i * int64(heap.ptrSize)))
+					// Likewise for a large array.
+					size := int64(typ.Field("Size_").Uintptr())
+					for e := int64(0); size > 0 && e+size <= elemSize; e += size {
+						for i := int64(0); i < nptrs; i++ {
+							if p.proc.ReadUint8(gcdata.Add(i/8))>>uint(i%8)&1 != 0 {
+								heap.setIsPointer(min.Add(e + i*int64(heap.ptrSize)))
+							}
 						}
 					}
 				}
@@ -596,6 +1027,18 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 		}
 	}
 
+	if badInlineMarks > 0 {
+		p.warnings = append(p.warnings, fmt.Sprintf("%d small object spans have unexpected inline mark bits; ignoring pointers in them", badInlineMarks))
+	}
+
+	p.report("spans", n, n)
+	sort.Slice(p.spans, func(i, j int) bool { return p.spans[i].Base < p.spans[j].Base })
+	p.spanStats = make([]SpanClassStat, 0, len(classStats))
+	for _, cs := range classStats {
+		p.spanStats = append(p.spanStats, *cs)
+	}
+	sort.Slice(p.spanStats, func(i, j int) bool { return p.spanStats[i].Class < p.spanStats[j].Class })
+
 	// Also count pages in the page cache for each P.
 	allp := p.rtGlobals["allp"]
 	for i := int64(0); i < allp.SliceLen(); i++ {
@@ -612,6 +1055,7 @@ func readHeap0(p *Process, mheap region, arenas []arena, arenaBaseOffset int64)
 		leafStat("readonly", stats.readOnly),
 		leafStat("data", stats.data),
 		leafStat("bss", stats.bss),
+		leafStat("other mmap", stats.otherMmap),
 		groupStat("heap",
 			groupStat("in use spans",
 				leafStat("alloc", stats.allocSize),
@@ -636,6 +1080,9 @@ func readGoroutines(p *Process, dwarfVars map[*Func][]dwarfVar) ([]*Goroutine, e
 	n := allgs.SliceLen()
 	var goroutines []*Goroutine
 	for i := int64(0); i < n; i++ {
+		if i%64 == 0 {
+			p.report("goroutines", i, n)
+		}
 		r := allgs.SliceIndex(i).Deref()
 		g, err := readGoroutine(p, r, dwarfVars)
 		if err != nil {
@@ -646,14 +1093,62 @@ func readGoroutines(p *Process, dwarfVars map[*Func][]dwarfVar) ([]*Goroutine, e
 		}
 		goroutines = append(goroutines, g)
 	}
+	p.report("goroutines", n, n)
 	return goroutines, nil
 }
 
+// readAncestors reads the creation stacks recorded in g.ancestors.
+// See runtime.saveAncestors.
+func readAncestors(p *Process, r region) [][]*Frame {
+	if !r.HasField("ancestors") {
+		return nil
+	}
+	ap := r.Field("ancestors")
+	if ap.Address() == 0 {
+		return nil
+	}
+	a := ap.Deref()
+	n := a.SliceLen()
+	var ancestors [][]*Frame
+	for i := int64(0); i < n; i++ {
+		pcs := a.SliceIndex(i).Field("pcs")
+		var frames []*Frame
+		for j := int64(0); j < pcs.SliceLen(); j++ {
+			pc := core.Address(pcs.SliceIndex(j).Uintptr())
+			f := p.funcTab.find(pc)
+			if f == nil {
+				continue
+			}
+			if f.name == "runtime.goexit" {
+				break
+			}
+			// The pcs are all return addresses. See runtime.printAncestorTracebackFuncInfo.
+			fr := &Frame{f: f, pc: pc, posPC: pc}
+			if pc > f.entry {
+				fr.posPC--
+			}
+			if len(frames) > 0 {
+				frames[len(frames)-1].parent = fr
+			}
+			frames = append(frames, fr)
+		}
+		ancestors = append(ancestors, frames)
+	}
+	return ancestors
+}
+
 func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Goroutine, error) {
 	// Set up register descriptors for DWARF stack programs to be executed.
 	g := &Goroutine{r: r}
 	stk := r.Field("stack")
-	g.stackSize = int64(stk.Field("hi").Uintptr() - stk.Field("lo").Uintptr())
+	g.stackLo = core.Address(stk.Field("lo").Uintptr())
+	g.stackHi = core.Address(stk.Field("hi").Uintptr())
+	g.ancestors = readAncestors(p, r)
+
+	// The stacks that frames of this goroutine may be on: its own, and
+	// the system and signal stacks of the M running it, if any.
+	type stackRange struct{ lo, hi core.Address }
+	stacks := []stackRange{{g.stackLo, g.stackHi}}
 
 	var osT *core.Thread // os thread working on behalf of this G (if any).
 	mp := r.Field("m")
@@ -666,11 +1161,42 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 				osT = t
 			}
 		}
+		for _, name := range []string{"g0", "gsignal"} {
+			if gp := m.Field(name); gp.Address() != 0 {
+				stk := gp.Deref().Field("stack")
+				stacks = append(stacks, stackRange{core.Address(stk.Field("lo").Uintptr()), core.Address(stk.Field("hi").Uintptr())})
+			}
+		}
+	}
+	findStack := func(sp core.Address) int {
+		for i, s := range stacks {
+			if s.lo <= sp && sp < s.hi {
+				return i
+			}
+		}
+		return -1
+	}
+	// Every frame takes at least a word of stack, which bounds the
+	// number of frames there can be.
+	var maxFrames int64
+	for _, s := range stacks {
+		maxFrames += s.hi.Sub(s.lo) / p.proc.PtrSize()
 	}
 	st := r.Field("atomicstatus").Field("value")
 	status := st.Uint32()
 	status &^= uint32(p.rtConsts.get("runtime._Gscan"))
+	g.status = fmt.Sprintf("unknown status %d", status)
+	if names, ok := p.rtGlobals["gStatusStrings"]; ok && int64(status) < names.ArrayLen() && names.ArrayIndex(int64(status)).String() != "" {
+		g.status = names.ArrayIndex(int64(status)).String()
+	}
+	if status == uint32(p.rtConsts.get("runtime._Gwaiting")) {
+		wr := int64(r.Field("waitreason").Uint8())
+		if reasons, ok := p.rtGlobals["waitReasonStrings"]; ok && wr < reasons.ArrayLen() {
+			g.waitReason = reasons.ArrayIndex(wr).String()
+		}
+	}
 	var sp, pc core.Address
+	var bp core.Address // frame pointer, if known; used to skip non-Go frames
 	switch status {
 	case uint32(p.rtConsts.get("runtime._Gidle")):
 		return g, nil
@@ -678,13 +1204,23 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 		sched := r.Field("sched")
 		sp = core.Address(sched.Field("sp").Uintptr())
 		pc = core.Address(sched.Field("pc").Uintptr())
+		bp = core.Address(sched.Field("bp").Uintptr())
 	case uint32(p.rtConsts.get("runtime._Grunning")):
 		sp = osT.SP()
 		pc = osT.PC()
+		for _, reg := range osT.Regs() {
+			if reg.Name == "rbp" {
+				bp = core.Address(reg.Value)
+			}
+		}
 		// TODO: back up to the calling frame?
 	case uint32(p.rtConsts.get("runtime._Gsyscall")):
 		sp = core.Address(r.Field("syscallsp").Uintptr())
 		pc = core.Address(r.Field("syscallpc").Uintptr())
+		if r.HasField("syscallbp") {
+			// Go 1.23+
+			bp = core.Address(r.Field("syscallbp").Uintptr())
+		}
 		// TODO: or should we use the osT registers?
 	case uint32(p.rtConsts.get("runtime._Gdead")):
 		return nil, nil
@@ -706,24 +1242,72 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 	regs := op.NewDwarfRegisters(p.proc.StaticBase(), dregs, binary.LittleEndian, regnum.AMD64_Rip, regnum.AMD64_Rsp, regnum.AMD64_Rbp, 0)
 
 	// Read all the frames.
+	fail := func(err error) {
+		g.unwindErr = &UnwindError{SP: sp, PC: pc, Frames: len(g.frames), Err: err}
+		p.warnings = append(p.warnings, fmt.Sprintf("goroutine %d: %v", r.Field("goid").Uint64(), g.unwindErr))
+	}
+	regsKnown := osT != nil && status == uint32(p.rtConsts.get("runtime._Grunning"))
+	lastStack, lastSP := -1, core.Address(0)
 	for {
+		if p.funcTab.find(pc) == nil && bp != 0 {
+			// Not Go code. Probably C code reached via cgo.
+			// Try to skip over it to the Go code that called it.
+			if csp, cpc := unwindCFrames(p, bp); cpc != 0 {
+				sp, pc = csp, cpc
+			}
+		}
+		stack := findStack(sp)
+		if stack < 0 {
+			fail(fmt.Errorf("stack pointer is outside the goroutine's stack [%#x,%#x)", g.stackLo, g.stackHi))
+			break
+		}
+		if stack == lastStack && sp <= lastSP {
+			// Stacks grow down, so callers' frames are at higher addresses.
+			fail(fmt.Errorf("stack pointer did not increase from %#x", lastSP))
+			break
+		}
+		if int64(len(g.frames)) >= maxFrames {
+			fail(fmt.Errorf("too many frames"))
+			break
+		}
+		lastStack, lastSP = stack, sp
 		f, err := readFrame(p, sp, pc)
 		if err != nil {
-			goid := r.Field("goid").Uint64()
-			fmt.Printf("warning: giving up on backtrace for %d after %d frames: %v\n", goid, len(g.frames), err)
+			fail(err)
+			break
+		}
+		f.regs = regsKnown
+		if f.max > stacks[stack].hi {
+			fail(fmt.Errorf("frame of %s extends past the top of its stack at %#x", f.f.name, stacks[stack].hi))
 			break
 		}
 		if f.f.name == "runtime.goexit" {
 			break
 		}
+		f.posPC = f.pc
 		if len(g.frames) > 0 {
-			g.frames[len(g.frames)-1].parent = f
+			callee := g.frames[len(g.frames)-1]
+			callee.parent = f
+			if callee.f.name != "runtime.sigpanic" {
+				// f.pc is a return address. Use the call instead.
+				// (After a fault, it's the faulting instruction.)
+				f.posPC--
+			}
 		}
 		g.frames = append(g.frames, f)
 
 		regs.CFA = int64(f.max)
 		regs.FrameBase = int64(f.max)
 
+		// At an asynchronous preemption point there are no stack maps,
+		// so the runtime scans the preempted frame, and that of
+		// asyncPreempt holding the spilled registers, conservatively.
+		// Do the same: treat every word that points into the heap as a
+		// live pointer. Some of them may really be dead.
+		if f.f.name == "runtime.asyncPreempt" || len(g.frames) > 1 && g.frames[len(g.frames)-2].f.name == "runtime.asyncPreempt" {
+			p.scanConservatively(f)
+		}
+
 		// Start with all pointer slots as unnamed.
 		unnamed := map[core.Address]bool{}
 		for a := range f.Live {
@@ -762,12 +1346,17 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 						rp.kind = immPiece
 						rp.value = p.Val
 					}
+					rps = append(rps, rp)
 					off += int64(p.Size)
 				}
-				f.roots = append(f.roots, p.makeCompositeRoot(v.name, v.typ, f, rps))
+				r := p.makeCompositeRoot(v.name, v.typ, f, rps)
+				f.roots = append(f.roots, r)
+				f.locals = append(f.locals, LocalVar{Name: v.name, Type: v.typ, Root: r})
 			} else if addr != 0 && len(pieces) == 0 {
 				// Simple contiguous stack location.
-				f.roots = append(f.roots, p.makeMemRoot(v.name, v.typ, f, core.Address(addr)))
+				r := p.makeMemRoot(v.name, v.typ, f, core.Address(addr))
+				f.roots = append(f.roots, r)
+				f.locals = append(f.locals, LocalVar{Name: v.name, Type: v.typ, Addr: core.Address(addr), Root: r})
 
 				// Remove this variable from the set of unnamed pointers.
 				for a := core.Address(addr); a < core.Address(addr).Add(v.typ.Size); a = a.Add(p.proc.PtrSize()) {
@@ -831,7 +1420,8 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 			// interrupted normal execution.
 
 			// ctxt is a *ucontext
-			mctxt := ctxt.Add(5 * 8)
+			// Skip uc_flags, uc_link and uc_stack.
+			mctxt := ctxt.Add(5 * p.proc.PtrSize())
 			// mctxt is a *mcontext
 			// TODO: totally arch-dependent!
 
@@ -885,7 +1475,7 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 			readReg("r15")
 			readReg("rdi")
 			readReg("rsi")
-			readReg("rbp")
+			bp = core.Address(readReg("rbp"))
 			readReg("rbx")
 			readReg("rdx")
 			readReg("rax")
@@ -900,9 +1490,16 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 			// Update register state.
 			dregs := hardwareRegs2DWARF(hregs)
 			regs = op.NewDwarfRegisters(p.proc.StaticBase(), dregs, binary.LittleEndian, regnum.AMD64_Rip, regnum.AMD64_Rsp, regnum.AMD64_Rbp, 0)
+			regsKnown = true
 		} else {
+			regsKnown = false
 			sp = f.max
-			pc = core.Address(p.proc.ReadUintptr(sp - 8)) // TODO:amd64 only
+			pc = p.proc.ReadPtr(sp.Add(-p.proc.PtrSize())) // TODO:amd64 only
+			if f.max.Sub(f.min) > p.proc.PtrSize() {
+				// The frame saved its caller's frame pointer
+				// just below the return address.
+				bp = p.proc.ReadPtr(sp.Add(-2 * p.proc.PtrSize())) // TODO:amd64 only
+			}
 		}
 		if pc == 0 {
 			// TODO: when would this happen?
@@ -913,11 +1510,54 @@ func readGoroutine(p *Process, r region, dwarfVars map[*Func][]dwarfVar) (*Gorou
 			sched := r.Field("sched")
 			sp = core.Address(sched.Field("sp").Uintptr())
 			pc = core.Address(sched.Field("pc").Uintptr())
+			bp = core.Address(sched.Field("bp").Uintptr())
 		}
 	}
 	return g, nil
 }
 
+// unwindCFrames follows the frame pointer chain starting at bp through
+// frames of non-Go code, such as C code called via cgo, until it finds a
+// return address in Go code. It returns the stack pointer and pc of that
+// Go frame, or 0, 0 if the chain can't be followed.
+// This only works if the non-Go code maintains frame pointers.
+func unwindCFrames(p *Process, bp core.Address) (sp, pc core.Address) {
+	ptrSize := p.proc.PtrSize()
+	readPtr := func(a core.Address) (core.Address, bool) {
+		if !p.proc.ReadableN(a, ptrSize) {
+			return 0, false
+		}
+		return p.proc.ReadPtr(a), true
+	}
+	isGo := func(pc core.Address) bool {
+		return p.funcTab.find(pc) != nil
+	}
+	return walkFramePointers(bp, ptrSize, readPtr, isGo)
+}
+
+// walkFramePointers does the work of unwindCFrames, reading memory with
+// readPtr and recognizing Go code with isGo.
+func walkFramePointers(bp core.Address, ptrSize int64, readPtr func(core.Address) (core.Address, bool), isGo func(core.Address) bool) (sp, pc core.Address) {
+	for i := 0; i < 1000 && bp != 0; i++ {
+		// TODO: amd64 only. The saved frame pointer is at bp,
+		// and the return address is just above it.
+		pc, ok := readPtr(bp.Add(ptrSize))
+		if !ok {
+			break
+		}
+		if isGo(pc) {
+			return bp.Add(2 * ptrSize), pc
+		}
+		next, ok := readPtr(bp)
+		if !ok || next <= bp {
+			// Stacks grow down, so callers' frames are at higher addresses.
+			break
+		}
+		bp = next
+	}
+	return 0, 0
+}
+
 func readFrame(p *Process, sp, pc core.Address) (*Frame, error) {
 	f := p.funcTab.find(pc)
 	if f == nil {
@@ -932,73 +1572,89 @@ func readFrame(p *Process, sp, pc core.Address) (*Frame, error) {
 
 	frame := &Frame{f: f, pc: pc, min: sp, max: sp.Add(size)}
 
-	// Find live ptrs in locals
+	// Find live ptrs in locals and args. If a stack map can't be read,
+	// don't give up on the frame (and with it, the rest of the backtrace).
+	// Just treat the affected slots as containing no pointers, or with
+	// FlagConservativeFrames, scan the frame conservatively.
 	live := map[core.Address]bool{}
-	if x := int(p.rtConsts.get("internal/abi.FUNCDATA_LocalsPointerMaps")); x < len(f.funcdata) {
-		addr := f.funcdata[x]
-		// TODO: Ideally we should have the same frame size check as
-		// runtime.getStackSize to detect errors when we are missing
-		// the stackmap.
-		if addr != 0 {
-			locals := region{p: p.proc, a: addr, typ: p.rtTypeByName["runtime.stackmap"]}
-			n := locals.Field("n").Int32()       // # of bitmaps
-			nbit := locals.Field("nbit").Int32() // # of bits per bitmap
-			idx, err := f.stackMap.find(off)
-			if err != nil {
-				return nil, fmt.Errorf("cannot read stack map at pc=%#x: %v", pc, err)
-			}
-			if idx < -1 {
-				return nil, fmt.Errorf("cannot read stack map at pc=%#x: invalid stack map index %d", pc, idx)
-			}
-			if idx == -1 {
-				idx = 0
-			}
-			if idx < int64(n) {
-				bits := locals.Field("bytedata").a.Add(int64(nbit+7) / 8 * idx)
-				base := frame.max.Add(-16).Add(-int64(nbit) * p.proc.PtrSize())
-				// TODO: -16 for amd64. Return address and parent's frame pointer
-				for i := int64(0); i < int64(nbit); i++ {
-					if p.proc.ReadUint8(bits.Add(i/8))>>uint(i&7)&1 != 0 {
-						live[base.Add(i*p.proc.PtrSize())] = true
-					}
-				}
+	frame.Live = live
+	// The locals are below the return address and saved frame pointer.
+	// Like runtime.getStackMap, expect a stack map if there are any.
+	// TODO: that's amd64's frame layout.
+	locals := frame.max.Add(-2 * p.proc.PtrSize())
+	missing := locals > sp
+	if x := int(p.rtConsts.get("internal/abi.FUNCDATA_LocalsPointerMaps")); x < len(f.funcdata) && f.funcdata[x] != 0 {
+		bits, nbit, err := readStackMap(p, f, off, f.funcdata[x])
+		if err != nil {
+			p.warnings = append(p.warnings, fmt.Sprintf("ignoring locals of %s: cannot read stack map at pc=%#x: %v", f.name, pc, err))
+		} else {
+			missing = false
+		}
+		base := locals.Add(-nbit * p.proc.PtrSize())
+		for i := int64(0); i < nbit; i++ {
+			if p.proc.ReadUint8(bits.Add(i/8))>>uint(i&7)&1 != 0 {
+				live[base.Add(i*p.proc.PtrSize())] = true
 			}
 		}
 	}
-	// Same for args
 	if x := int(p.rtConsts.get("internal/abi.FUNCDATA_ArgsPointerMaps")); x < len(f.funcdata) {
-		addr := f.funcdata[x]
-		if addr != 0 {
-			args := region{p: p.proc, a: addr, typ: p.rtTypeByName["runtime.stackmap"]}
-			n := args.Field("n").Int32()       // # of bitmaps
-			nbit := args.Field("nbit").Int32() // # of bits per bitmap
-			idx, err := f.stackMap.find(off)
-			if err != nil {
-				return nil, fmt.Errorf("cannot read stack map at pc=%#x: %v", pc, err)
-			}
-			if idx < -1 {
-				return nil, fmt.Errorf("cannot read stack map at pc=%#x: invalid stack map index %d", pc, idx)
-			}
-			if idx == -1 {
-				idx = 0
-			}
-			if idx < int64(n) {
-				bits := args.Field("bytedata").a.Add((int64(nbit+7) / 8) * idx)
-				base := frame.max
-				// TODO: add to base for LR archs.
-				for i := int64(0); i < int64(nbit); i++ {
-					if p.proc.ReadUint8(bits.Add(i/8))>>uint(i&7)&1 != 0 {
-						live[base.Add(i*p.proc.PtrSize())] = true
-					}
-				}
+		bits, nbit, err := readStackMap(p, f, off, f.funcdata[x])
+		if err != nil {
+			p.warnings = append(p.warnings, fmt.Sprintf("ignoring args of %s: cannot read stack map at pc=%#x: %v", f.name, pc, err))
+		}
+		base := frame.max
+		// TODO: add to base for LR archs.
+		for i := int64(0); i < nbit; i++ {
+			if p.proc.ReadUint8(bits.Add(i/8))>>uint(i&7)&1 != 0 {
+				live[base.Add(i*p.proc.PtrSize())] = true
 			}
 		}
 	}
-	frame.Live = live
+	if missing && p.conservativeFrames {
+		p.scanConservatively(frame)
+	}
 
 	return frame, nil
 }
 
+// scanConservatively marks f as scanned conservatively, and every word
+// in it that points into the heap as a live pointer.
+func (p *Process) scanConservatively(f *Frame) {
+	f.conservative = true
+	for a := f.min; a < f.max; a = a.Add(p.proc.PtrSize()) {
+		if p.heap.get(p.proc.ReadPtr(a)) != nil {
+			f.Live[a] = true
+		}
+	}
+}
+
+// readStackMap finds the pointer bitmap for pc offset off in the
+// runtime.stackmap at addr, for function f. It returns the address of the
+// bitmap and its length in bits. A zero length means there are no pointers,
+// either because addr is 0 or because the stack map can't be read.
+func readStackMap(p *Process, f *Func, off int64, addr core.Address) (core.Address, int64, error) {
+	if addr == 0 {
+		return 0, 0, nil
+	}
+	sm := region{p: p.proc, a: addr, typ: p.rtTypeByName["runtime.stackmap"]}
+	n := sm.Field("n").Int32()       // # of bitmaps
+	nbit := sm.Field("nbit").Int32() // # of bits per bitmap
+	idx, err := f.stackMap.find(off)
+	if err != nil {
+		return 0, 0, err
+	}
+	if idx < -1 {
+		return 0, 0, fmt.Errorf("invalid stack map index %d", idx)
+	}
+	if idx == -1 {
+		idx = 0
+	}
+	if idx >= int64(n) {
+		return 0, 0, nil
+	}
+	return sm.Field("bytedata").a.Add(int64(nbit+7) / 8 * idx), int64(nbit), nil
+}
+
 // A Stats struct is the node of a tree representing the entire memory
 // usage of the Go program. Children of a node break its usage down
 // by category.
@@ -1011,6 +1667,57 @@ type Statistic struct {
 	children map[string]*Statistic
 }
 
+// A SpanClassStat describes the in-use heap spans of a single span class.
+type SpanClassStat struct {
+	// Class is the runtime span class: the size class shifted left
+	// by one, with the low bit set for spans of pointer-free objects.
+	Class uint8
+	// ElemSize is the size of an object slot. It is 0 for the
+	// large object classes, where each span holds one object of
+	// its own size.
+	ElemSize int64
+
+	Spans int64 // number of spans
+	Bytes int64 // total size of the spans
+	Slots int64 // total number of object slots
+	Alloc int64 // number of allocated object slots
+	Tail  int64 // bytes at the end of spans too small to hold another slot
+
+	// Occupancy is a histogram of how full the spans are.
+	// Occupancy[i] counts the spans with between i/10 and (i+1)/10
+	// of their slots allocated. Full spans are counted in Occupancy[9].
+	Occupancy [10]int64
+}
+
+// A SpanInfo describes a single in-use heap span.
+type SpanInfo struct {
+	Base     core.Address // address of the start of the span
+	Size     int64        // size of the span in bytes
+	Class    uint8        // span class, as in SpanClassStat
+	ElemSize int64        // size of an object slot
+	Slots    int64        // number of object slots
+	Alloc    int64        // number of allocated object slots
+}
+
+// A Special is a special record the runtime has attached to a heap
+// object, such as a finalizer, a cleanup or a heap profile record.
+type Special struct {
+	Obj  core.Address // address of the object the record is attached to
+	Kind string       // kind of record, e.g. "Finalizer", "Cleanup" or "Profile"
+	Addr core.Address // address of the record; it starts with a runtime.special
+}
+
+// mappingFile returns the name of the file m was originally mapped from.
+// For mappings that aren't copy-on-write, that is the file its contents
+// are read from, which may be the core file itself.
+func mappingFile(m *core.Mapping) string {
+	if name, _ := m.OrigSource(); name != "" {
+		return name
+	}
+	name, _ := m.Source()
+	return name
+}
+
 func leafStat(name string, value int64) *Statistic {
 	return &Statistic{Name: name, Value: value}
 }
@@ -106,3 +106,33 @@ func (p *Process) ForEachReversePtr(y Object, fn func(x Object, r *Root, i, j in
 		}
 	}
 }
+
+// ScanReversePtrs is like ForEachReversePtr, but instead of building the
+// reverse edge index it finds the pointers to y by scanning every object
+// and root. Each call takes time proportional to the size of the heap,
+// so ScanReversePtrs is only a win for a handful of queries, where the
+// memory used by the index isn't worth paying for.
+func (p *Process) ScanReversePtrs(y Object, fn func(x Object, r *Root, i, j int64) bool) {
+	ok := true
+	p.ForEachObject(func(x Object) bool {
+		p.ForEachPtr(x, func(i int64, z Object, j int64) bool {
+			if z == y {
+				ok = fn(x, nil, i, j)
+			}
+			return ok
+		})
+		return ok
+	})
+	if !ok {
+		return
+	}
+	p.ForEachRoot(func(r *Root) bool {
+		p.ForEachRootPtr(r, func(i int64, z Object, j int64) bool {
+			if z == y {
+				ok = fn(0, r, i, j)
+			}
+			return ok
+		})
+		return ok
+	})
+}
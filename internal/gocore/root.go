@@ -32,6 +32,13 @@ func (r *Root) HasAddress() bool {
 	return len(r.pieces) == 1 && r.pieces[0].kind == addrPiece
 }
 
+// Conservative reports whether the pointers in r might be dead, because
+// r is in a frame that is scanned conservatively. Objects only reachable
+// through such roots may not really be retained.
+func (r *Root) Conservative() bool {
+	return r.Frame != nil && r.Frame.conservative
+}
+
 // Addr returns the address of the root, if it has one.
 func (r *Root) Addr() core.Address {
 	if r.HasAddress() {
@@ -68,6 +75,14 @@ func (p *Process) makeCompositeRoot(name string, typ *Type, fr *Frame, pieces []
 	return r
 }
 
+// ReadRoot returns a copy of the contents of r, which may be spread
+// over memory and registers.
+func (p *Process) ReadRoot(r *Root) []byte {
+	b := make([]byte, r.Type.Size)
+	p.readRootAt(r, b, 0)
+	return b
+}
+
 func (pr *Process) readRootPtr(r *Root, offset int64) core.Address {
 	// TODO(mknyszek): Little-endian only.
 	ptrBuf := make([]byte, pr.proc.PtrSize())
@@ -135,9 +150,8 @@ func walkRootTypePtrs(p *Process, r *Root, ptrBuf []byte, off int64, t *Type, fn
 		// The first word is a type or itab.
 		// Itabs are never in the heap.
 		// Types might be, though.
-		// We have no idea about the liveness of registers, when a == 0.
 		a := p.readRootAt(r, ptrBuf[:p.proc.PtrSize()], off)
-		if a != 0 && (r.Frame == nil || r.Frame.Live[a]) {
+		if rootPtrLive(p, r, a, off) {
 			var ptr core.Address
 			if p.proc.PtrSize() == 4 {
 				ptr = core.Address(binary.LittleEndian.Uint32(ptrBuf[:]))
@@ -153,7 +167,7 @@ func walkRootTypePtrs(p *Process, r *Root, ptrBuf []byte, off int64, t *Type, fn
 		fallthrough
 	case KindPtr, KindString, KindSlice, KindFunc:
 		a := p.readRootAt(r, ptrBuf[:p.proc.PtrSize()], off)
-		if a != 0 && (r.Frame == nil || r.Frame.Live[a]) {
+		if rootPtrLive(p, r, a, off) {
 			var ptr core.Address
 			if p.proc.PtrSize() == 4 {
 				ptr = core.Address(binary.LittleEndian.Uint32(ptrBuf[:]))
@@ -181,6 +195,26 @@ func walkRootTypePtrs(p *Process, r *Root, ptrBuf []byte, off int64, t *Type, fn
 	return true
 }
 
+// rootPtrLive reports whether the pointer at offset off in r, read from
+// address a (0 if it wasn't read from memory), is live. Pointers in
+// memory are live if the frame's stack map says so. Pointers in
+// registers are live if the registers hold the frame's values, since
+// DWARF only places live variables in registers.
+func rootPtrLive(p *Process, r *Root, a core.Address, off int64) bool {
+	if a != 0 {
+		return r.Frame == nil || r.Frame.Live[a]
+	}
+	if r.Frame == nil || !r.Frame.regs {
+		return false
+	}
+	for _, piece := range r.pieces {
+		if piece.off == off {
+			return piece.kind == regPiece && piece.size == p.proc.PtrSize()
+		}
+	}
+	return false
+}
+
 type rootPieceKind int
 
 const (
@@ -0,0 +1,99 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import "golang.org/x/debug/internal/core"
+
+// Constants from sync and internal/sync. They aren't always in DWARF,
+// as only the packages that use them emit them.
+const (
+	mutexLocked       = 1
+	mutexWaiterShift  = 3
+	rwmutexMaxReaders = 1 << 30
+)
+
+// RWMutexState describes the state of a sync.RWMutex.
+type RWMutexState struct {
+	Writer        bool  // a writer holds the lock
+	WriterWaiting bool  // a writer is waiting for Readers to release the lock
+	Readers       int32 // readers holding the lock
+	ReaderWaiters int32 // readers blocked until the writer is done
+	WriterWaiters int32 // further writers blocked behind the current one
+}
+
+// MutexState reports whether the sync.Mutex at a is locked, and how many
+// goroutines are blocked waiting to acquire it. ok is false if the
+// program's DWARF doesn't describe sync.Mutex, as when it has no mutexes.
+func (p *Process) MutexState(a core.Address) (locked bool, waiters int32, ok bool) {
+	s, ok := p.syncRegion(a, "sync.Mutex")
+	if !ok {
+		return false, 0, false
+	}
+	if s.HasField("mu") {
+		// Since Go 1.24, sync.Mutex wraps an internal/sync.Mutex.
+		s = s.Field("mu")
+	}
+	state := s.Field("state").Int32()
+	return state&mutexLocked != 0, state >> mutexWaiterShift, true
+}
+
+// RWMutexState returns the state of the sync.RWMutex at a. ok is false
+// if the program's DWARF doesn't describe sync.RWMutex.
+func (p *Process) RWMutexState(a core.Address) (s RWMutexState, ok bool) {
+	rw, ok := p.syncRegion(a, "sync.RWMutex")
+	if !ok {
+		return s, false
+	}
+	locked, waiters, ok := p.MutexState(rw.Field("w").a)
+	if !ok {
+		return s, false
+	}
+	n := rw.Field("readerCount").Field("v").Int32()
+	wait := rw.Field("readerWait").Field("v").Int32()
+
+	if n >= 0 {
+		// No writer. (Or one has just acquired w, and is about to announce
+		// itself in readerCount.)
+		s.Readers = n
+	} else {
+		// A writer holds w, and has subtracted rwmutexMaxReaders from
+		// readerCount. wait is the number of readers it is waiting for.
+		// Every other reader is blocked until it is done.
+		n += rwmutexMaxReaders
+		s.Writer = wait == 0
+		s.WriterWaiting = wait > 0
+		s.Readers = wait
+		s.ReaderWaiters = n - wait
+	}
+	if locked {
+		s.WriterWaiters = waiters
+	}
+	return s, true
+}
+
+// WaitGroupState returns the counter of the sync.WaitGroup at a, and the
+// number of goroutines blocked in its Wait method. ok is false if the
+// program's DWARF doesn't describe sync.WaitGroup.
+func (p *Process) WaitGroupState(a core.Address) (counter, waiters int32, ok bool) {
+	wg, ok := p.syncRegion(a, "sync.WaitGroup")
+	if !ok {
+		return 0, 0, false
+	}
+	state := wg.Field("state").Field("v").Uint64()
+	// The counter is in the high 32 bits and the waiters in the low 31.
+	// Since Go 1.25, bit 31 marks WaitGroups in a synctest bubble.
+	return int32(state >> 32), int32(state & 0x7fffffff), true
+}
+
+// syncRegion returns a region for the value of the named sync type at a,
+// and whether the type is in DWARF. It isn't in stripped binaries, or
+// ones that don't use it.
+func (p *Process) syncRegion(a core.Address, name string) (region, bool) {
+	t := p.rtTypeByName[name]
+	if t == nil {
+		return region{}, false
+	}
+	return region{p: p.proc, a: a, typ: t}, true
+}
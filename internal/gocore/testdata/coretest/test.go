@@ -1,8 +1,11 @@
 package main
 
 import (
+	"io"
 	"os"
 	"runtime"
+	"runtime/trace"
+	"sync"
 )
 
 // Large is an object that (since Go 1.22) is allocated in a span that has a
@@ -13,7 +16,12 @@ type Large struct {
 	arr [32768 - 8]uint8
 }
 
+// globalLarge keeps the Large object live when the only other
+// reference to it, in a register, is unknown without DWARF.
+var globalLarge *Large
+
 func useLarge(o *Large, ready chan<- struct{}) {
+	globalLarge = o
 	o.ptr = &o.arr[5]
 	o.arr[5] = 0xCA
 	ready <- struct{}{}
@@ -122,6 +130,20 @@ type Xer interface {
 	X() int64
 }
 
+// ptrXer is stored directly in an interface, for TestInterfaceValue.
+type ptrXer struct {
+	x int64
+}
+
+func (p *ptrXer) X() int64 {
+	return p.x
+}
+
+var (
+	globalXer    Xer
+	globalNilXer Xer
+)
+
 var globalAnyTree AnyTree
 var globalAnyTreeFM func() int
 var globalTypeSafeTree TypeSafeTree[myPair]
@@ -129,6 +151,169 @@ var globalTypeSafeTreeFM func() int
 
 var block = make(chan struct{})
 
+// Synchronization primitives in a known state, for TestSyncState.
+var (
+	globalMutex     sync.Mutex
+	globalRWMutex   sync.RWMutex
+	globalWaitGroup sync.WaitGroup
+)
+
+// Objects with special records attached, for TestSpecials. They have
+// their own type so as not to change the counts TestObjects checks.
+type specialObj struct {
+	x, y int64
+}
+
+var (
+	globalFinalized *specialObj
+	globalCleanedUp *specialObj
+)
+
+// makeSpecials is separate from main so its closures don't renumber
+// main's, which tests look for by name.
+func makeSpecials() {
+	globalFinalized = &specialObj{1, 2}
+	runtime.SetFinalizer(globalFinalized, func(*specialObj) {})
+	globalCleanedUp = &specialObj{3, 4}
+	runtime.AddCleanup(globalCleanedUp, func(int) {}, 0)
+}
+
+// profObj is allocated while every allocation is profiled, for
+// TestAllocProfile.
+type profObj struct {
+	n, tag int64
+}
+
+var globalProfiled [10]*profObj
+
+// makeProfiled allocates globalProfiled's objects with the memory
+// profiler sampling every allocation. Nothing calls runtime.MemProfile,
+// so otherwise it is off.
+//
+//go:noinline
+func makeProfiled() {
+	runtime.MemProfileRate = 1
+	for i := range globalProfiled {
+		globalProfiled[i] = &profObj{n: int64(i), tag: 0x50524f46}
+	}
+	runtime.MemProfileRate = 0
+}
+
+// globalPipe is the write end of a pipe whose read end a goroutine
+// blocks reading, in the network poller, for TestNetPollers.
+var globalPipe *os.File
+
+//go:noinline
+func startPipeReader() {
+	r, w, err := os.Pipe()
+	if err != nil {
+		panic(err)
+	}
+	globalPipe = w
+	ready := make(chan struct{})
+	go func() {
+		ready <- struct{}{}
+		var b [1]byte
+		r.Read(b[:])
+	}()
+	<-ready
+}
+
+// startTracing leaves execution tracing on when the core is taken, for
+// TestTraceData. It's optional, as the tracer's allocations would upset
+// other tests' object counts.
+//
+//go:noinline
+func startTracing() {
+	if err := trace.Start(io.Discard); err != nil {
+		panic(err)
+	}
+}
+
+// finObj's finalizers and cleanups are left queued to run when the
+// core is taken, for TestPendingFinalizers.
+type finObj struct {
+	n, tag int64
+}
+
+// queueFinalizers frees objects with finalizers and cleanups that block
+// forever, so the first of each to run blocks the rest. It's optional,
+// as the GC it forces would upset other tests' object counts.
+//
+//go:noinline
+func queueFinalizers() {
+	started := make(chan struct{}, 2)
+	for i := int64(0); i < 5; i++ {
+		runtime.SetFinalizer(&finObj{i, 0x46494e}, func(*finObj) {
+			started <- struct{}{}
+			select {}
+		})
+		runtime.AddCleanup(&finObj{i, 0x434c4e}, func(int64) {
+			started <- struct{}{}
+			select {}
+		}, i)
+	}
+	runtime.GC()
+	<-started
+	<-started
+}
+
+// spinObj is only referenced by a goroutine that is asynchronously
+// preempted when the core is taken, for TestConservativeFrames.
+type spinObj struct {
+	n, tag int64
+}
+
+//go:noinline
+func spin(o *spinObj, ready chan<- struct{}) {
+	ready <- struct{}{}
+	for i := int64(0); ; i++ {
+		o.n += i
+	}
+}
+
+// startSpinner is separate from main so its goroutine's wrapper doesn't
+// renumber main's, which tests look for by name.
+//
+//go:noinline
+func startSpinner() {
+	ready := make(chan struct{})
+	go spin(&spinObj{tag: 0x5350494e}, ready)
+	<-ready
+}
+
+// Maps whose keys and elems are too big to store in their groups,
+// so the groups hold pointers to them, for TestMaps.
+type mapKey struct {
+	id  int64
+	pad [20]int64
+}
+
+type mapVal struct {
+	leaf *mapLeaf
+	pad  [20]int64
+}
+
+type mapLeaf struct {
+	n, tag int64
+}
+
+var (
+	globalBigMap   map[mapKey]mapVal  // large enough to need a directory of tables
+	globalSmallMap map[int64]*mapLeaf // a single group
+)
+
+func makeMaps() {
+	globalBigMap = make(map[mapKey]mapVal)
+	for i := int64(0); i < 100; i++ {
+		globalBigMap[mapKey{id: i}] = mapVal{leaf: &mapLeaf{i, 0x4d4150}}
+	}
+	globalSmallMap = make(map[int64]*mapLeaf)
+	for i := int64(0); i < 3; i++ {
+		globalSmallMap[i] = &mapLeaf{i, 0x4d4150}
+	}
+}
+
 var a anyNode
 
 func init() {
@@ -145,6 +330,26 @@ func init() {
 func main() {
 	globalAnyTree.root = makeAnyTree(5)
 	globalTypeSafeTree.root = makeTypeSafeTree(5)
+	globalXer = &ptrXer{x: 0x58}
+	if globalNilXer != nil {
+		panic("globalNilXer isn't nil")
+	}
+
+	globalMutex.Lock()
+	globalRWMutex.RLock()
+	globalRWMutex.RLock()
+	globalWaitGroup.Add(3)
+	makeSpecials()
+	makeMaps()
+	makeProfiled()
+	startPipeReader()
+	if os.Getenv("GO_DEBUG_TEST_TRACE") != "" {
+		startTracing()
+	}
+	if os.Getenv("GO_DEBUG_TEST_FINALIZERS") != "" {
+		queueFinalizers()
+	}
+	startSpinner()
 
 	ready := make(chan struct{})
 	go func() {
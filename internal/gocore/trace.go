@@ -0,0 +1,131 @@
+// Copyright 2026 The Go Authors. All rights reserved.
+// Use of this source code is governed by a BSD-style
+// license that can be found in the LICENSE file.
+
+package gocore
+
+import (
+	"errors"
+	"fmt"
+	"go/version"
+)
+
+// evEndOfGeneration is the in-band event separating the generations of
+// a trace, added in Go 1.26. See internal/trace/tracev2.
+const evEndOfGeneration = 52
+
+// TraceData returns the execution trace data the runtime had buffered
+// when the core was taken, if runtime/trace was tracing the program, in
+// the format runtime/trace writes, so it can be given to "go tool
+// trace". It returns nil and no error if there is none.
+//
+// The data is what the runtime hadn't yet handed to the writer passed
+// to trace.Start, including the partly filled buffers of each M, and so
+// covers only the last moments of the program. The runtime writes a
+// generation's clock frequency and its stack and string tables when the
+// generation ends, which the last one in the core hasn't, so "go tool
+// trace" rejects it as truncated; "go tool trace -d=wire" still
+// decodes its events.
+//
+// Only the tracer of Go 1.22 and later is supported.
+func (p *Process) TraceData() ([]byte, error) {
+	tr, ok := p.rtGlobals["trace"]
+	bufType := p.rtTypeByName["runtime.traceBuf"]
+	if !ok || bufType == nil || !tr.HasField("readerGen") {
+		return nil, errors.New("unsupported execution tracer; only Go 1.22 and later's is")
+	}
+	gen := tr.Field("gen").Field("value").Uintptr()
+	readerGen := max(tr.Field("readerGen").Field("value").Uintptr(), 1)
+
+	// A generation's buffers are first the per-M ones it's writing to,
+	// then queued on trace.full[gen%2] for the reader.
+	type batch struct {
+		r       region // the traceBuf
+		partial bool   // still being written by an M
+	}
+	var batches [2][]batch
+	full := tr.Field("full")
+	for i := range batches {
+		for b := full.ArrayIndex(int64(i)).Field("head"); b.Address() != 0; b = b.Deref().Field("traceBufHeader").Field("link") {
+			batches[i] = append(batches[i], batch{r: b.Deref()})
+		}
+	}
+	if allm, ok := p.rtGlobals["allm"]; ok {
+		for mp := allm; mp.Address() != 0; mp = mp.Deref().Field("alllink") {
+			bufs := mp.Deref().Field("trace").Field("buf")
+			for i := range batches {
+				gbufs := []region{bufs.ArrayIndex(int64(i))}
+				if a := gbufs[0]; a.typ.Kind == KindArray {
+					// Since Go 1.24, a buffer per experiment.
+					gbufs = gbufs[:0]
+					for j := int64(0); j < a.ArrayLen(); j++ {
+						gbufs = append(gbufs, a.ArrayIndex(j))
+					}
+				}
+				for _, b := range gbufs {
+					if b.Address() != 0 {
+						batches[i] = append(batches[i], batch{r: b.Deref(), partial: true})
+					}
+				}
+			}
+		}
+	}
+	if len(batches[0])+len(batches[1]) == 0 {
+		return nil, nil
+	}
+
+	v := p.traceVersion()
+	data := fmt.Appendf(nil, "go 1.%d trace\x00\x00\x00", v)
+	for g := readerGen; g <= max(gen, readerGen); g++ {
+		if g > readerGen && v >= 26 {
+			data = append(data, evEndOfGeneration)
+		}
+		for _, b := range batches[g%2] {
+			h := b.r.Field("traceBufHeader")
+			n := h.Field("pos").Int()
+			if n <= 0 {
+				continue
+			}
+			buf := make([]byte, n)
+			p.proc.ReadAt(buf, b.r.Field("arr").a)
+			if b.partial && h.HasField("lenPos") {
+				// The runtime fills in the batch's length, a varint
+				// padded to traceBytesPerNumber bytes, when it
+				// flushes the buffer.
+				const traceBytesPerNumber = 10
+				pos := h.Field("lenPos").Int()
+				if pos+traceBytesPerNumber > n {
+					continue
+				}
+				v := uint64(n - (pos + traceBytesPerNumber))
+				for i := range int64(traceBytesPerNumber) {
+					buf[pos+i] = 0x80 | byte(v)
+					v >>= 7
+				}
+				buf[pos+traceBytesPerNumber-1] &^= 0x80
+			}
+			data = append(data, buf...)
+		}
+	}
+	return data, nil
+}
+
+// traceVersion returns the version of the execution trace format the
+// runtime writes, which changes only in some releases. See
+// internal/trace/version.
+func (p *Process) traceVersion() int {
+	v := p.buildVersion
+	if !version.IsValid(v) {
+		// A development version; assume it's the latest.
+		return 26
+	}
+	switch {
+	case version.Compare(v, "go1.23") < 0:
+		return 22
+	case version.Compare(v, "go1.25") < 0:
+		return 23
+	case version.Compare(v, "go1.26") < 0:
+		return 25
+	}
+	return 26
+}
@@ -6,7 +6,9 @@ package gocore
 
 import (
 	"fmt"
+	"iter"
 	"reflect"
+	"sort"
 	"strings"
 
 	"golang.org/x/debug/internal/core"
@@ -26,6 +28,9 @@ type Type struct {
 	// Go-specific types obtained from AttrGoRuntimeType.
 	// May be nil if this type is not referenced by the DWARF.
 	goAddr core.Address
+	// For defined types with a basic underlying type, like time.Duration,
+	// the predeclared type, like int64.
+	underlying *Type
 
 	// Fields only valid for a subset of kinds.
 	Count  int64   // for kind == KindArray
@@ -82,6 +87,19 @@ func (t *Type) String() string {
 	return t.Name
 }
 
+// Underlying returns the underlying type of a defined type whose
+// underlying type is a predeclared boolean, numeric, or string type.
+// For example, the underlying type of time.Duration is int64.
+// DWARF doesn't record the underlying type of other defined types, such
+// as structs, so for those, and for types that aren't defined types,
+// Underlying returns t.
+func (t *Type) Underlying() *Type {
+	if t.underlying != nil {
+		return t.underlying
+	}
+	return t
+}
+
 func (t *Type) field(name string) *Field {
 	if t.Kind != KindStruct {
 		panic("asking for field of non-struct")
@@ -99,6 +117,146 @@ func (t *Type) HasField(name string) bool {
 	return t.field(name) != nil
 }
 
+// FieldNameAt returns the path, relative to a value of type t, of the
+// field at offset off: for example ".next", "[3].x" or ".s.len".
+// It returns ".???" if t has no field at off.
+func (t *Type) FieldNameAt(off int64) string {
+	switch t.Kind {
+	case KindBool, KindInt, KindUint, KindFloat:
+		return ""
+	case KindComplex:
+		if off == 0 {
+			return ".real"
+		}
+		return ".imag"
+	case KindIface, KindEface:
+		if off == 0 {
+			return ".type"
+		}
+		return ".data"
+	case KindPtr, KindFunc:
+		return ""
+	case KindString:
+		if off == 0 {
+			return ".ptr"
+		}
+		return ".len"
+	case KindSlice:
+		if off == 0 {
+			return ".ptr"
+		}
+		if off <= t.Size/2 {
+			return ".len"
+		}
+		return ".cap"
+	case KindArray:
+		s := t.Elem.Size
+		if s == 0 {
+			// An array of zero-sized elements has no field at any offset.
+			break
+		}
+		i := off / s
+		return fmt.Sprintf("[%d]%s", i, t.Elem.FieldNameAt(off-i*s))
+	case KindStruct:
+		for _, f := range t.Fields {
+			if f.Off <= off && off < f.Off+f.Type.Size {
+				return "." + f.Name + f.Type.FieldNameAt(off-f.Off)
+			}
+		}
+	}
+	return ".???"
+}
+
+// sameType reports whether s and t describe the same layout.
+// Element and field types are compared by name only.
+func sameType(s, t *Type) bool {
+	return typeConflict(s, t) == ""
+}
+
+// typeConflict returns a description of the first difference found
+// between s and t, or "" if there is none.
+func typeConflict(s, t *Type) string {
+	switch {
+	case s.Name != t.Name:
+		return fmt.Sprintf("name %s != %s", s.Name, t.Name)
+	case s.Kind != t.Kind:
+		return fmt.Sprintf("%s: kind %s != %s", s.Name, s.Kind, t.Kind)
+	case s.Size != t.Size:
+		return fmt.Sprintf("%s: size %d != %d", s.Name, s.Size, t.Size)
+	case s.Count != t.Count:
+		return fmt.Sprintf("%s: count %d != %d", s.Name, s.Count, t.Count)
+	case (s.Elem == nil) != (t.Elem == nil) || s.Elem != nil && s.Elem.Name != t.Elem.Name:
+		return fmt.Sprintf("%s: element type %v != %v", s.Name, s.Elem, t.Elem)
+	case len(s.Fields) != len(t.Fields):
+		return fmt.Sprintf("%s: %d fields != %d fields", s.Name, len(s.Fields), len(t.Fields))
+	}
+	for i := range s.Fields {
+		f, g := &s.Fields[i], &t.Fields[i]
+		if f.Name != g.Name || f.Off != g.Off || f.Type.Name != g.Type.Name {
+			return fmt.Sprintf("%s: field %d is %s %s at %d != %s %s at %d", s.Name, i, f.Name, f.Type, f.Off, g.Name, g.Type, g.Off)
+		}
+	}
+	return ""
+}
+
+// SetTypeNameFormatter makes f format the names of types wherever p
+// produces them for people to read (see TypeName), for example to
+// shorten long import paths. f receives the name the type has in the
+// core, like "map[string]*github.com/foo/bar.Baz"; Type.Name and
+// Type.String still return that. A nil f restores the default.
+//
+// SetTypeNameFormatter must not be called concurrently with other
+// methods of p.
+func (p *Process) SetTypeNameFormatter(f func(string) string) {
+	p.typeNameFormatter = f
+}
+
+// TypeName returns the name of t as formatted by the function passed
+// to SetTypeNameFormatter, or t.Name if there is none.
+func (p *Process) TypeName(t *Type) string {
+	if p.typeNameFormatter == nil {
+		return t.Name
+	}
+	return p.typeNameFormatter(t.Name)
+}
+
+// Types returns all the types p knows about: those described by DWARF,
+// those built from runtime type information, and those synthesized while
+// typing the heap (closures, for example). Types are not canonical, so
+// several entries may share a name. The result is sorted by name and size.
+func (p *Process) Types() []*Type {
+	p.typeHeap()
+	seen := map[*Type]bool{}
+	var types []*Type
+	add := func(t *Type) {
+		if t != nil && !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	if !p.runtimeOnly {
+		// Otherwise the DWARF types are another program's.
+		for _, t := range p.dwarfTypeMap {
+			add(t)
+		}
+	}
+	p.rtTypeMu.Lock()
+	for _, t := range p.rtTypeMap {
+		add(t)
+	}
+	p.rtTypeMu.Unlock()
+	for _, ti := range p.types {
+		add(ti.t)
+	}
+	sort.Slice(types, func(i, j int) bool {
+		if types[i].Name != types[j].Name {
+			return types[i].Name < types[j].Name
+		}
+		return types[i].Size < types[j].Size
+	})
+	return types
+}
+
 // DynamicType returns the concrete type stored in the interface type t at address a.
 // If the interface is nil, returns nil.
 func (p *Process) DynamicType(t *Type, a core.Address) *Type {
@@ -201,7 +359,10 @@ func (r runtimeItab) Type() *Field {
 // If "d" is 0, just return *Type and not to do the interface disambiguation.
 // Guaranteed to return a non-nil *Type.
 func (p *Process) runtimeType2Type(a core.Address, d core.Address) *Type {
-	if t := p.rtTypeMap[a]; t != nil {
+	p.rtTypeMu.Lock()
+	t := p.rtTypeMap[a]
+	p.rtTypeMu.Unlock()
+	if t != nil {
 		return t
 	}
 	// There's no corresponding DWARF type. Make our own.
@@ -211,22 +372,12 @@ func (p *Process) runtimeType2Type(a core.Address, d core.Address) *Type {
 	size := r.Size_()
 
 	// Find module this type is in.
-	var m *module
-	for _, x := range p.modules {
-		if x.types <= a && a < x.etypes {
-			m = x
-			break
-		}
-	}
+	m := p.findModule(a)
 
 	// Read information out of the runtime._type.
 	var name string
 	if m != nil {
-		x := m.types.Add(r.Str())
-		i, n := readNameLen(p, x)
-		b := make([]byte, n)
-		p.proc.ReadAt(b, x.Add(i+1))
-		name = string(b)
+		name = p.readName(m.types.Add(r.Str())).name
 		if r.TFlag()&uint8(p.rtConsts.get("internal/abi.TFlagExtraStar")) != 0 {
 			name = name[1:]
 		}
@@ -254,7 +405,7 @@ func (p *Process) runtimeType2Type(a core.Address, d core.Address) *Type {
 	}
 
 	// Build the type from the name, size, and ptr/nonptr bits.
-	t := &Type{Name: name, Size: size, Kind: KindStruct}
+	t = &Type{Name: name, Size: size, Kind: KindStruct}
 	n := t.Size / ptrSize
 
 	// Types to use for ptr/nonptr fields of runtime types which
@@ -282,57 +433,71 @@ func (p *Process) runtimeType2Type(a core.Address, d core.Address) *Type {
 		// TODO: tail of <ptrSize data.
 	}
 
-	// Memoize.
+	// Memoize. If another goroutine got here first, use its type, so that
+	// each runtime type maps to a single *Type.
+	p.rtTypeMu.Lock()
+	defer p.rtTypeMu.Unlock()
+	if t0 := p.rtTypeMap[a]; t0 != nil {
+		return t0
+	}
 	p.rtTypeMap[a] = t
 	return t
 }
 
-// ptrs returns a sorted list of pointer offsets in t.
-func (t *Type) ptrs() []int64 {
-	return t.ptrs1(nil, 0)
+// ptrs returns the offsets of the pointers in t, in increasing order.
+// The offsets are generated as they are needed, so even huge types
+// like [1e9]*byte can be handled.
+func (t *Type) ptrs() iter.Seq[int64] {
+	return func(yield func(int64) bool) {
+		t.ptrs1(0, yield)
+	}
 }
-func (t *Type) ptrs1(s []int64, off int64) []int64 {
+
+// ptrs1 calls yield with the offsets of the pointers in t, plus off.
+// It reports whether yield always returned true.
+func (t *Type) ptrs1(off int64, yield func(int64) bool) bool {
 	switch t.Kind {
 	case KindPtr, KindFunc, KindSlice, KindString:
-		s = append(s, off)
+		return yield(off)
 	case KindIface, KindEface:
-		s = append(s, off, off+t.Size/2)
+		return yield(off) && yield(off+t.Size/2)
 	case KindArray:
-		if t.Count > 10000 {
-			// Be careful about really large types like [1e9]*byte.
-			// To process such a type we'd make a huge ptrs list.
-			// The ptrs list here is only used for matching
-			// a runtime type with a dwarf type, and for making
-			// fields for types with no dwarf type.
-			// Both uses can fail with no terrible repercussions.
-			// We still will scan the whole object during markObjects, for example.
-			// TODO: make this more robust somehow.
+		if !t.Elem.hasPtrs() {
+			// Don't walk every element of large pointer-free arrays.
 			break
 		}
 		for i := int64(0); i < t.Count; i++ {
-			s = t.Elem.ptrs1(s, off)
-			off += t.Elem.Size
+			if !t.Elem.ptrs1(off+i*t.Elem.Size, yield) {
+				return false
+			}
 		}
 	case KindStruct:
 		for _, f := range t.Fields {
-			s = f.Type.ptrs1(s, off+f.Off)
+			if !f.Type.ptrs1(off+f.Off, yield) {
+				return false
+			}
 		}
 	default:
 		// no pointers
 	}
-	return s
+	return true
 }
 
-func equal(a, b []int64) bool {
-	if len(a) != len(b) {
-		return false
-	}
-	for i, x := range a {
-		if x != b[i] {
-			return false
+// hasPtrs reports whether t contains any pointers.
+func (t *Type) hasPtrs() bool {
+	switch t.Kind {
+	case KindPtr, KindFunc, KindSlice, KindString, KindIface, KindEface:
+		return true
+	case KindArray:
+		return t.Count > 0 && t.Elem.hasPtrs()
+	case KindStruct:
+		for _, f := range t.Fields {
+			if f.Type.hasPtrs() {
+				return true
+			}
 		}
 	}
-	return true
+	return false
 }
 
 // A typeInfo contains information about the type of an object.
@@ -542,18 +707,44 @@ func (p *Process) doTypeHeap() {
 	})
 
 	// Propagate typings through the heap.
-	for len(work) > 0 {
-		c := work[len(work)-1]
-		work = work[:len(work)-1]
-		switch c.t.Kind {
-		case KindBool, KindInt, KindUint, KindFloat, KindComplex:
-			// Don't do O(n) function calls for big primitive slices
+	propagate := func() {
+		for len(work) > 0 {
+			c := work[len(work)-1]
+			work = work[:len(work)-1]
+			switch c.t.Kind {
+			case KindBool, KindInt, KindUint, KindFloat, KindComplex:
+				// Don't do O(n) function calls for big primitive slices
+				continue
+			}
+			for i := int64(0); i < c.r; i++ {
+				p.typeObject(c.a.Add(i*c.t.Size), c.t, p.proc, add)
+			}
+		}
+	}
+	propagate()
+
+	// Large objects the roots didn't type, for example because they
+	// are only referenced from frames with no DWARF, have their
+	// runtime type recorded in their span. Start from those too, in
+	// address order, so the typing doesn't depend on map order.
+	large := make([]core.Address, 0, len(p.largeTypes))
+	for a := range p.largeTypes {
+		large = append(large, a)
+	}
+	sort.Slice(large, func(i, j int) bool { return large[i] < large[j] })
+	for _, a := range large {
+		typ := p.largeTypes[a]
+		i, _ := p.findObjectIndex(a)
+		if i < 0 || p.types[i].t != nil {
 			continue
 		}
-		for i := int64(0); i < c.r; i++ {
-			p.typeObject(c.a.Add(i*c.t.Size), c.t, p.proc, add)
+		t := p.runtimeType2Type(typ, 0)
+		if t.Size == 0 {
+			continue
 		}
+		add(a, t, p.Size(Object(a))/t.Size)
 	}
+	propagate()
 
 	// Merge any interior typings with the 0-offset typing.
 	for i, chunks := range interior {
@@ -610,9 +801,57 @@ func methodFromMethodValueWrapper(name string) (string, bool) {
 // ifaceIndir reports whether t is stored indirectly in an interface value.
 func ifaceIndir(t core.Address, p *Process) bool {
 	typr := p.findRuntimeType(t)
+	// Since Go 1.26, a type flag says so, not its kind.
+	if flag, ok := p.rtConsts.find("internal/abi.TFlagDirectIface"); ok {
+		return typr.TFlag()&uint8(flag) == 0
+	}
 	return typr.Kind_()&uint8(p.rtConsts.get("internal/abi.KindDirectIface")) == 0
 }
 
+// InterfaceValue decodes the interface of type t (which must be an
+// interface type) stored at address a. It returns the concrete type of
+// the value in the interface and the address at which that value is stored.
+// indirect reports whether the interface holds a pointer to the value
+// (in which case data is that pointer) rather than the value itself
+// (in which case data is the address of the interface's data word).
+// For a nil interface, InterfaceValue returns nil, 0, false.
+func (p *Process) InterfaceValue(a core.Address, t *Type) (concrete *Type, data core.Address, indirect bool) {
+	if t.Kind != KindEface && t.Kind != KindIface {
+		panic("asking for the value of a non-interface")
+	}
+	return p.interfaceValue(a, t, p.proc)
+}
+
+func (p *Process) interfaceValue(a core.Address, t *Type, r reader) (*Type, core.Address, bool) {
+	// Load the data word first and back out if its a nil
+	// or typed-nil interface. We must do this because it's
+	// our only signal that this value is dead. Consider
+	// a dead eface variable on the stack: the data field
+	// will return nil because it's dead, but the type pointer
+	// will likely be bogus.
+	data := a.Add(p.proc.PtrSize())
+	if r.ReadPtr(data) == 0 { // nil interface
+		return nil, 0, false
+	}
+	// Use the type word to determine the type
+	// of the pointed-to object.
+	typPtr := r.ReadPtr(a)
+	if typPtr == 0 { // nil interface
+		return nil, 0, false
+	}
+	if t.Kind == KindIface {
+		typPtr = p.proc.ReadPtr(typPtr.Add(p.findItab().Type().Off))
+	}
+	// TODO: for KindEface, type typPtr. It might point to the heap
+	// if the type was allocated with reflect.
+	typ := p.runtimeType2Type(typPtr, data)
+	if ifaceIndir(typPtr, p) {
+		// Read through the extra level of indirection.
+		return typ, r.ReadPtr(data), true
+	}
+	return typ, data, false
+}
+
 // typeObject takes an address and a type for the data at that address.
 // For each pointer it finds in the memory at that address, it calls add with the pointer
 // and the type + repeat count of the thing that it points to.
@@ -624,34 +863,14 @@ func (p *Process) typeObject(a core.Address, t *Type, r reader, add func(core.Ad
 		// Nothing to do
 	case KindEface, KindIface:
 		// Interface.
-		//
-		// Load the data word first and back out if its a nil
-		// or typed-nil interface. We must do this because it's
-		// our only signal that this value is dead. Consider
-		// a dead eface variable on the stack: the data field
-		// will return nil because it's dead, but the type pointer
-		// will likely be bogus.
-		data := a.Add(ptrSize)
-		if r.ReadPtr(data) == 0 { // nil interface
-			return
-		}
-		// Use the type word to determine the type
-		// of the pointed-to object.
-		typPtr := r.ReadPtr(a)
-		if typPtr == 0 { // nil interface
+		typ, data, indirect := p.interfaceValue(a, t, r)
+		if typ == nil { // nil interface
 			return
 		}
-		if t.Kind == KindIface {
-			typPtr = p.proc.ReadPtr(typPtr.Add(p.findItab().Type().Off))
-		}
-		// TODO: for KindEface, type typPtr. It might point to the heap
-		// if the type was allocated with reflect.
-		typ := p.runtimeType2Type(typPtr, data)
-		if ifaceIndir(typPtr, p) {
+		if indirect {
 			// Indirect interface: the interface introduced a new
 			// level of indirection, not reflected in the type.
-			// Read through it.
-			add(r.ReadPtr(data), typ, 1)
+			add(data, typ, 1)
 			return
 		}
 
@@ -756,7 +975,36 @@ func (p *Process) typeObject(a core.Address, t *Type, r reader, add func(core.Ad
 			add(bPtr, bTyp, n)
 			// TODO: also oldbuckets
 		}
+		// Go 1.24+ maps are Swiss tables; see internal/runtime/maps.
+		// Like the buckets above, some of their pointers type whole
+		// arrays. Key and elem types too big to store in a group are
+		// stored indirectly, and the group type has pointers to them,
+		// so typing the groups types the keys and elems too.
+		var skip string
+		switch {
+		case strings.HasPrefix(t.Name, "map<"):
+			// dirPtr points to an array of dirLen table pointers, or,
+			// for a small map with dirLen 0, to a single group.
+			dirPtr := t.field("dirPtr")
+			ptr := r.ReadPtr(a.Add(dirPtr.Off))
+			if n := r.ReadInt(a.Add(t.field("dirLen").Off)); n > 0 {
+				add(ptr, dirPtr.Type.Elem, n)
+			} else {
+				add(ptr, swissGroupType(dirPtr.Type.Elem.Elem), 1)
+			}
+			skip = "dirPtr"
+		case strings.HasPrefix(t.Name, "table<"):
+			// groups.data points to an array of lengthMask+1 groups.
+			groups := t.field("groups")
+			ptr := r.ReadPtr(a.Add(groups.Off + groups.Type.field("data").Off))
+			n := r.ReadInt(a.Add(groups.Off + groups.Type.field("lengthMask").Off))
+			add(ptr, swissGroupType(t), n+1)
+			skip = "groups"
+		}
 		for _, f := range t.Fields {
+			if f.Name == skip {
+				continue
+			}
 			// hchan.buf(in chan) is an unsafe.pointer to an [dataqsiz]elemtype.
 			if strings.HasPrefix(t.Name, "hchan<") && f.Name == "buf" && f.Type.Kind == KindPtr {
 				elemType := p.proc.ReadPtr(a.Add(t.field("elemtype").Off))
@@ -772,6 +1020,12 @@ func (p *Process) typeObject(a core.Address, t *Type, r reader, add func(core.Ad
 	}
 }
 
+// swissGroupType returns the group type of the Swiss table type
+// table<K,V>, from its groups.data field.
+func swissGroupType(table *Type) *Type {
+	return table.field("groups").Type.field("data").Type.Elem
+}
+
 // forEachPointer iterates over each pointer in the type, emitting the offset of the
 // pointer in the type.
 func (t *Type) forEachPointer(baseOffset, ptrSize int64, yield func(off int64)) {